@@ -0,0 +1,479 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/stats"
+)
+
+// ErrUnknownCompression is returned when configuring an encoding that is not supported.
+var ErrUnknownCompression = errors.New("unknown compression")
+
+// Compression encodings.
+const (
+	CompressionGzip   = "gzip"
+	CompressionZstd   = "zstd"
+	CompressionBrotli = "br"
+)
+
+// CompressionConfig contains configuration options for a Compression.
+type CompressionConfig struct {
+	// Encodings are the enabled encodings, in order of preference when negotiating HTTP responses.
+	// Defaults to br, zstd and gzip.
+	Encodings []string `envconfig:"ENCODINGS" default:"br,zstd,gzip"`
+	// MinSize is the size in bytes HTTP responses are compressed from, smaller responses are sent as they are.
+	MinSize int `envconfig:"MIN_SIZE" default:"1024"`
+}
+
+// Compression compresses the HTTP responses, see WithHTTPCompression, and the gRPC messages, see
+// WithGRPCCompressors. It is a prometheus.Collector exporting the bytes before and after compression, the bytes
+// saved and the compression ratio, by protocol and encoding.
+type Compression struct {
+	config CompressionConfig
+
+	uncompressed *prometheus.CounterVec
+	compressed   *prometheus.CounterVec
+	saved        *prometheus.CounterVec
+	ratio        *prometheus.HistogramVec
+}
+
+// NewCompression creates a Compression, panics with ErrUnknownCompression when an encoding is not supported.
+func NewCompression(config CompressionConfig) *Compression {
+	if len(config.Encodings) == 0 {
+		config.Encodings = []string{CompressionBrotli, CompressionZstd, CompressionGzip}
+	}
+
+	for _, e := range config.Encodings {
+		if _, ok := httpEncoders[e]; !ok {
+			panic(fmt.Errorf("%w: %s", ErrUnknownCompression, e))
+		}
+	}
+
+	labels := []string{"protocol", "encoding"}
+
+	return &Compression{
+		config: config,
+		uncompressed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "compression_uncompressed_bytes_total",
+			Help: "Total number of bytes before compression.",
+		}, labels),
+		compressed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "compression_compressed_bytes_total",
+			Help: "Total number of bytes after compression.",
+		}, labels),
+		saved: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "compression_saved_bytes_total",
+			Help: "Total number of bytes saved by compression.",
+		}, labels),
+		ratio: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "compression_ratio",
+			Help:    "Ratio of the uncompressed to the compressed size of the compressed payloads.",
+			Buckets: []float64{1, 1.5, 2, 3, 5, 10, 20, 50},
+		}, labels),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Compression) Describe(ch chan<- *prometheus.Desc) {
+	c.uncompressed.Describe(ch)
+	c.compressed.Describe(ch)
+	c.saved.Describe(ch)
+	c.ratio.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Compression) Collect(ch chan<- prometheus.Metric) {
+	c.uncompressed.Collect(ch)
+	c.compressed.Collect(ch)
+	c.saved.Collect(ch)
+	c.ratio.Collect(ch)
+}
+
+func (c *Compression) observe(protocol, encoding string, uncompressed, compressed int) {
+	if uncompressed == 0 || compressed == 0 {
+		return
+	}
+
+	c.uncompressed.WithLabelValues(protocol, encoding).Add(float64(uncompressed))
+	c.compressed.WithLabelValues(protocol, encoding).Add(float64(compressed))
+	c.saved.WithLabelValues(protocol, encoding).Add(float64(max(0, uncompressed-compressed)))
+	c.ratio.WithLabelValues(protocol, encoding).Observe(float64(uncompressed) / float64(compressed))
+}
+
+// WithHTTPCompression compresses the responses with the encoding negotiated through Accept-Encoding, when they
+// are at least CompressionConfig.MinSize bytes long.
+// Apply to REST, GRPCRest, HealthCheck and Metrics server instances.
+func WithHTTPCompression(c *Compression) Option {
+	return func(srv any) {
+		switch s := srv.(type) {
+		case *REST:
+			s.httpServer.Handler = c.Handler(s.httpServer.Handler)
+		case *Metrics:
+			s.compression = c
+		}
+	}
+}
+
+// WithGRPCCompressors observes the gRPC compression of the server responses and restricts it to the enabled
+// encodings, the responses to the requests compressed with another encoding are sent uncompressed.
+//
+// The option does not register the gRPC compressors: the gRPC compressor registry is global to the process, so
+// they are registered by importing a package, e.g. grpccompressors for gzip and zstd:
+//
+//	import _ "github.com/dohernandez/servers/grpccompressors"
+//
+// Once registered, every gRPC server and client of the process decompresses the messages compressed with them and
+// the servers respond with the encoding of the request. The requests compressed with an encoding not registered
+// fail with Unimplemented.
+// Apply to GRPC server instances.
+func WithGRPCCompressors(c *Compression) Option {
+	return func(srv any) {
+		s, ok := srv.(*GRPC)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		s.options.serverOpts = append(s.options.serverOpts, grpc.StatsHandler(grpcCompressionHandler{compression: c}))
+
+		WithInterceptorBefore(StageRecovery, "compression", c.unaryServerInterceptor(), c.streamServerInterceptor())(srv)
+	}
+}
+
+// Handler returns the middleware compressing the responses of the handler.
+func (c *Compression) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := c.negotiate(r.Header.Get("Accept-Encoding"))
+
+		// Upgraded connections, e.g. WebSocket, need the hijackable writer.
+		if enc == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		cw := &compressResponseWriter{
+			ResponseWriter: w,
+			compression:    c,
+			encoding:       enc,
+		}
+
+		defer cw.finish()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate returns the preferred enabled encoding accepted by the client, empty when none.
+func (c *Compression) negotiate(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	accepted := make(map[string]bool)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q > 0
+	}
+
+	for _, e := range c.config.Encodings {
+		if ok, found := accepted[e]; found {
+			if ok {
+				return e
+			}
+
+			continue
+		}
+
+		if accepted["*"] {
+			return e
+		}
+	}
+
+	return ""
+}
+
+// httpEncoders create the writers of the supported encodings.
+var httpEncoders = map[string]func(w io.Writer) io.WriteCloser{
+	CompressionGzip: func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	},
+	CompressionZstd: func(w io.Writer) io.WriteCloser {
+		zw, _ := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1)) //nolint:errcheck // Options are valid.
+
+		return zw
+	},
+	CompressionBrotli: func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriter(w)
+	},
+}
+
+// uncompressibleContentTypes are the content type prefixes not compressed, already compressed or streamed.
+var uncompressibleContentTypes = []string{
+	"image/", "video/", "audio/", "application/zip", "application/gzip", "application/grpc", "application/connect+",
+	"text/event-stream",
+}
+
+// compressResponseWriter buffers the response until it is known to be large enough to be compressed.
+type compressResponseWriter struct {
+	http.ResponseWriter
+
+	compression *Compression
+	encoding    string
+
+	status  int
+	decided bool
+	buf     []byte
+
+	encoder io.WriteCloser
+	counter *countingWriter
+	written int
+}
+
+func (cw *compressResponseWriter) WriteHeader(code int) {
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if cw.decided {
+		return cw.write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+
+	if len(cw.buf) >= cw.compression.config.MinSize {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+func (cw *compressResponseWriter) write(b []byte) (int, error) {
+	if cw.encoder == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+
+	n, err := cw.encoder.Write(b)
+	cw.written += n
+
+	return n, err
+}
+
+// decide writes the header, compressed when large is set and the response can be compressed, and the buffered
+// body.
+func (cw *compressResponseWriter) decide(large bool) error {
+	cw.decided = true
+
+	h := cw.ResponseWriter.Header()
+
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if cw.compressible() {
+		h.Add("Vary", "Accept-Encoding")
+
+		if large {
+			h.Del("Content-Length")
+			h.Set("Content-Encoding", cw.encoding)
+
+			cw.counter = &countingWriter{w: cw.ResponseWriter}
+			cw.encoder = httpEncoders[cw.encoding](cw.counter)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	_, err := cw.write(buf)
+
+	return err
+}
+
+func (cw *compressResponseWriter) compressible() bool {
+	h := cw.ResponseWriter.Header()
+
+	if h.Get("Content-Encoding") != "" || cw.status < http.StatusOK ||
+		cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+
+	ct := h.Get("Content-Type")
+
+	for _, p := range uncompressibleContentTypes {
+		if strings.HasPrefix(ct, p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Flush sends the buffered response, uncompressed when it is not large enough yet.
+func (cw *compressResponseWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+
+		_ = cw.decide(len(cw.buf) >= cw.compression.config.MinSize) //nolint:errcheck
+	}
+
+	if f, ok := cw.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush() //nolint:errcheck
+	}
+
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// finish sends the response when it was not yet and closes the encoder.
+func (cw *compressResponseWriter) finish() {
+	if !cw.decided {
+		if cw.status == 0 {
+			// Nothing was written, the server writes the default response.
+			return
+		}
+
+		_ = cw.decide(false) //nolint:errcheck
+	}
+
+	if cw.encoder == nil {
+		return
+	}
+
+	_ = cw.encoder.Close() //nolint:errcheck
+
+	cw.compression.observe("http", cw.encoding, cw.written, cw.counter.n)
+}
+
+// countingWriter counts the bytes written.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += n
+
+	return n, err
+}
+
+// grpcCompressionKey is the context key of the grpcCompressionRPC.
+type grpcCompressionKey struct{}
+
+// grpcCompressionRPC is the encoding of the request and the response of a call.
+type grpcCompressionRPC struct {
+	request  atomic.Value
+	response atomic.Value
+}
+
+// grpcCompressionHandler is a stats.Handler observing the compression of the gRPC responses of a server.
+type grpcCompressionHandler struct {
+	compression *Compression
+}
+
+// TagRPC implements stats.Handler.
+func (grpcCompressionHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, grpcCompressionKey{}, &grpcCompressionRPC{})
+}
+
+// HandleRPC implements stats.Handler.
+func (h grpcCompressionHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	rpc, ok := ctx.Value(grpcCompressionKey{}).(*grpcCompressionRPC)
+	if !ok || s.IsClient() {
+		return
+	}
+
+	switch s := s.(type) {
+	case *stats.InHeader:
+		rpc.request.Store(s.Compression)
+	case *stats.OutHeader:
+		rpc.response.Store(s.Compression)
+	case *stats.OutPayload:
+		if enc, _ := rpc.response.Load().(string); enc != "" && enc != encoding.Identity { //nolint:errcheck
+			h.compression.observe("grpc", enc, s.Length, s.CompressedLength)
+		}
+	}
+}
+
+// TagConn implements stats.Handler.
+func (grpcCompressionHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn implements stats.Handler.
+func (grpcCompressionHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// restrictSendCompressor sends the response of the call uncompressed when the request encoding, which gRPC
+// responds with, is not enabled.
+func (c *Compression) restrictSendCompressor(ctx context.Context) {
+	rpc, ok := ctx.Value(grpcCompressionKey{}).(*grpcCompressionRPC)
+	if !ok {
+		return
+	}
+
+	if enc, _ := rpc.request.Load().(string); enc != "" && !slices.Contains(c.config.Encodings, enc) { //nolint:errcheck
+		_ = grpc.SetSendCompressor(ctx, encoding.Identity) //nolint:errcheck
+	}
+}
+
+func (c *Compression) unaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		c.restrictSendCompressor(ctx)
+
+		return handler(ctx, req)
+	}
+}
+
+func (c *Compression) streamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		c.restrictSendCompressor(ss.Context())
+
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,203 @@
+package servers_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/bool64/ctxd"
+	"github.com/dohernandez/servers"
+	_ "github.com/dohernandez/servers/grpccompressors"
+	"github.com/dohernandez/servers/serverstest"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// getEncoded gets the URL accepting the encodings, returning the decoded body and the response encoding.
+func getEncoded(t *testing.T, url, acceptEncoding string) (string, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer res.Body.Close() //nolint:errcheck
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var r io.Reader = res.Body
+
+	enc := res.Header.Get("Content-Encoding")
+
+	switch enc {
+	case "gzip":
+		r, err = gzip.NewReader(res.Body)
+		require.NoError(t, err)
+	case "zstd":
+		r, err = zstd.NewReader(res.Body)
+		require.NoError(t, err)
+	case "br":
+		r = brotli.NewReader(res.Body)
+	}
+
+	body, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return string(body), enc
+}
+
+func TestREST_WithHTTPCompression(t *testing.T) {
+	compression := servers.NewCompression(servers.CompressionConfig{MinSize: 1024})
+
+	large := strings.Repeat("hello compression ", 200)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/large", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, large) //nolint:errcheck
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "hello") //nolint:errcheck
+	})
+
+	srv := servers.NewREST(servers.Config{Name: "Test service", Host: "localhost"}, mux,
+		servers.WithAddrAssigned(),
+		servers.WithHTTPCompression(compression),
+	)
+
+	go func() {
+		_ = srv.Start() //nolint:errcheck
+	}()
+
+	defer srv.Stop()
+
+	base := fmt.Sprintf("http://%s", <-srv.AddrAssigned)
+
+	for accept, want := range map[string]string{
+		"gzip":                 "gzip",
+		"zstd, gzip":           "zstd",
+		"gzip;q=0.5, br":       "br",
+		"br;q=0, gzip":         "gzip",
+		"*":                    "br",
+		"deflate":              "",
+		"identity, gzip;q=0.0": "",
+	} {
+		t.Run(accept, func(t *testing.T) {
+			body, enc := getEncoded(t, base+"/large", accept)
+			assert.Equal(t, want, enc)
+			assert.Equal(t, large, body)
+		})
+	}
+
+	body, enc := getEncoded(t, base+"/small", "gzip")
+	assert.Empty(t, enc, "below the minimum size")
+	assert.Equal(t, "hello", body)
+
+	assert.Positive(t, savedBytes(t, compression, "http", "gzip"))
+	assert.Positive(t, savedBytes(t, compression, "http", "br"))
+}
+
+func TestGRPC_WithGRPCCompressors(t *testing.T) {
+	compression := servers.NewCompression(servers.CompressionConfig{})
+
+	s := serverstest.Start(t,
+		serverstest.WithGRPC(
+			servers.WithRegisterService(newGRPCTestServer(ctxd.NoOpLogger{})),
+			servers.WithGRPCCompressors(compression),
+		),
+		serverstest.WithMetrics(
+			servers.WithCollector(compression),
+			servers.WithHTTPCompression(compression),
+		),
+	)
+
+	name := strings.Repeat("a", 4096)
+
+	for _, enc := range []string{"gzip", "zstd"} {
+		reply, err := testdata.NewGreeterClient(s.GRPCConn).SayHello(context.Background(),
+			&testdata.HelloRequest{Name: name}, grpc.UseCompressor(enc))
+		require.NoError(t, err, enc)
+		assert.Equal(t, "Hello "+name, reply.GetMessage())
+
+		assert.Positive(t, savedBytes(t, compression, "grpc", enc), enc)
+	}
+
+	body, enc := getEncoded(t, s.MetricsURL+"/metrics", "gzip")
+	assert.Equal(t, "gzip", enc)
+	assert.Contains(t, body, `compression_saved_bytes_total{encoding="zstd",protocol="grpc"}`)
+
+	// The decompressed message is limited by the maximum received message size.
+	_, err := testdata.NewGreeterClient(s.GRPCConn).SayHello(context.Background(),
+		&testdata.HelloRequest{Name: strings.Repeat("a", 5<<20)}, grpc.UseCompressor("zstd"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestGRPC_WithGRPCCompressors_encodings(t *testing.T) {
+	compression := servers.NewCompression(servers.CompressionConfig{Encodings: []string{"gzip"}})
+
+	s := serverstest.Start(t,
+		serverstest.WithGRPC(
+			servers.WithRegisterService(newGRPCTestServer(ctxd.NoOpLogger{})),
+			servers.WithGRPCCompressors(compression),
+		),
+	)
+
+	name := strings.Repeat("a", 4096)
+
+	for _, enc := range []string{"gzip", "zstd"} {
+		reply, err := testdata.NewGreeterClient(s.GRPCConn).SayHello(context.Background(),
+			&testdata.HelloRequest{Name: name}, grpc.UseCompressor(enc))
+		require.NoError(t, err, enc)
+		assert.Equal(t, "Hello "+name, reply.GetMessage())
+	}
+
+	assert.Positive(t, savedBytes(t, compression, "grpc", "gzip"))
+	// The responses to the zstd requests are not compressed, zstd is not enabled.
+	assert.Zero(t, savedBytes(t, compression, "grpc", "zstd"))
+}
+
+// savedBytes returns the bytes saved by compression with the protocol and encoding.
+func savedBytes(t *testing.T, compression prometheus.Collector, protocol, encoding string) float64 {
+	t.Helper()
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(compression)
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	for _, f := range families {
+		if f.GetName() != "compression_saved_bytes_total" {
+			continue
+		}
+
+		for _, m := range f.GetMetric() {
+			labels := make(map[string]string)
+
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+
+			if labels["protocol"] == protocol && labels["encoding"] == encoding {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
@@ -4,18 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
 
 	"github.com/bool64/ctxd"
-	"github.com/bool64/zapctxd"
-	grpcLogging "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
-// ErrGRPCStart is returned when an error occurs the GRPC server start.
-var ErrGRPCStart = errors.New("start grpc server")
+var (
+	// ErrGRPCStart is returned when an error occurs the GRPC server start.
+	ErrGRPCStart = errors.New("start grpc server")
+	// ErrGRPCObserverSet is returned when attempting to set the GRPC observers more than once.
+	ErrGRPCObserverSet = errors.New("grpc observers already set")
+	// ErrGRPCRateLimiterSet is returned when attempting to set the GRPC rate limiter more than once.
+	ErrGRPCRateLimiterSet = errors.New("grpc rate limiter already set")
+)
 
 // GRPCRegisterService is an interface for a grpc service that provides registration.
 type GRPCRegisterService interface {
@@ -53,16 +58,24 @@ func WithReflection() Option {
 	}
 }
 
-// WithChainUnaryInterceptor sets the server interceptors for unary.
+// WithChainUnaryInterceptor sets the server interceptors for unary, running after StageValidation.
 // Apply to GRPC server instances.
 func WithChainUnaryInterceptor(interceptors ...grpc.UnaryServerInterceptor) Option {
-	return WithServerOption(grpc.ChainUnaryInterceptor(interceptors...))
+	return func(srv any) {
+		for _, i := range interceptors {
+			WithInterceptorAfter(StageValidation, "chain-unary-interceptor", i, nil)(srv)
+		}
+	}
 }
 
-// WithChainStreamInterceptor sets the server interceptors for stream.
+// WithChainStreamInterceptor sets the server interceptors for stream, running after StageValidation.
 // Apply to GRPC server instances.
 func WithChainStreamInterceptor(interceptors ...grpc.StreamServerInterceptor) Option {
-	return WithServerOption(grpc.ChainStreamInterceptor(interceptors...))
+	return func(srv any) {
+		for _, i := range interceptors {
+			WithInterceptorAfter(StageValidation, "chain-stream-interceptor", nil, i)(srv)
+		}
+	}
 }
 
 // WithServerOption sets the options for the grpc server.
@@ -79,15 +92,39 @@ func WithServerOption(opts ...grpc.ServerOption) Option {
 	}
 }
 
+// defaultGRPCMaxRecvMsgSize is the default max receive message size of the grpc server.
+const defaultGRPCMaxRecvMsgSize = 4 << 20
+
+// WithMaxRecvMsgSize sets the max message size in bytes the grpc server can receive, 4 MiB by default.
+// The Connect and gRPC-Web handlers bound the request bodies with it too, so prefer it over
+// WithServerOption(grpc.MaxRecvMsgSize(size)).
+// Apply to GRPC server instances.
+func WithMaxRecvMsgSize(size int) Option {
+	return func(srv any) {
+		s, ok := srv.(*GRPC)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		s.options.maxRecvMsgSize = size
+		s.options.serverOpts = append(s.options.serverOpts, grpc.MaxRecvMsgSize(size))
+	}
+}
+
 // GRPCObserver interface implemented by anything wants to append observer in the server thro UnaryServerInterceptor and StreamServerInterceptor.
 type GRPCObserver interface {
 	UnaryServerInterceptor() func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error)
 	StreamServerInterceptor() func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error
 }
 
-// WithGRPCObserver sets the GRPCObserver collector manager, used to append UnaryServerInterceptor and StreamServerInterceptor
+// WithGRPCObserver sets the GRPCObserver chain, used to append UnaryServerInterceptor and StreamServerInterceptor
+// of each observer in the given order.
+//
+// The observers run in StageMetrics, before the rate limiter, so they observe the rejected calls too.
+// Setting the observers more than once keeps the first ones, Start fails with ErrGRPCObserverSet.
 // Apply to GRPC server instances.
-func WithGRPCObserver(observer GRPCObserver) Option {
+func WithGRPCObserver(observers ...GRPCObserver) Option {
 	return func(srv any) {
 		s, ok := srv.(*GRPC)
 		if !ok {
@@ -95,17 +132,13 @@ func WithGRPCObserver(observer GRPCObserver) Option {
 			return
 		}
 
-		if s.options.observer != nil {
+		if s.options.observers != nil {
+			s.options.errs = append(s.options.errs, ErrGRPCObserverSet)
+
 			return
 		}
 
-		s.options.observer = observer
-
-		s.options.serverOpts = append(
-			s.options.serverOpts,
-			grpc.ChainUnaryInterceptor(observer.UnaryServerInterceptor()),
-			grpc.ChainStreamInterceptor(observer.StreamServerInterceptor()),
-		)
+		s.options.observers = append(make([]GRPCObserver, 0, len(observers)), observers...)
 	}
 }
 
@@ -123,7 +156,7 @@ func WithGrpcHealthCheck() Option {
 	}
 }
 
-// WithLogger sets service to use logger.
+// WithLogger sets service to use logger, logging the calls in StageLogging as configured by WithLoggingConfig.
 // Apply to GRPC server instances.
 func WithLogger(logger ctxd.Logger) Option {
 	return func(srv any) {
@@ -134,39 +167,9 @@ func WithLogger(logger ctxd.Logger) Option {
 		}
 
 		s.options.logger = logger
-
-		s.options.serverOpts = append(
-			s.options.serverOpts,
-			grpc.ChainUnaryInterceptor(grpcLogging.UnaryServerInterceptor(grpcInterceptorLogger(s.options.logger))),
-			grpc.ChainStreamInterceptor(grpcLogging.StreamServerInterceptor(grpcInterceptorLogger(s.options.logger))),
-		)
 	}
 }
 
-// grpcInterceptorLogger adapts zapctxd logger to interceptor logger.
-func grpcInterceptorLogger(l ctxd.Logger) grpcLogging.Logger {
-	return grpcLogging.LoggerFunc(func(ctx context.Context, lvl grpcLogging.Level, msg string, fields ...any) {
-		ctx = ctxd.AddFields(ctx, fields...)
-
-		if sl, ok := l.(interface{ SkipCaller() *zapctxd.Logger }); ok {
-			l = sl.SkipCaller()
-		}
-
-		switch lvl {
-		case grpcLogging.LevelDebug:
-			l.Debug(ctx, msg)
-		case grpcLogging.LevelInfo:
-			l.Info(ctx, msg)
-		case grpcLogging.LevelWarn:
-			l.Warn(ctx, msg)
-		case grpcLogging.LevelError:
-			l.Error(ctx, msg)
-		default:
-			panic(fmt.Sprintf("unknown level %v", lvl))
-		}
-	})
-}
-
 // NewGRPC initiates a new wrapped grpc server.
 func NewGRPC(config Config, opts ...Option) *GRPC {
 	srv := &GRPC{}
@@ -177,6 +180,81 @@ func NewGRPC(config Config, opts ...Option) *GRPC {
 		o(srv)
 	}
 
+	var builtin []namedInterceptor
+
+	if srv.options.logger != nil {
+		unary, stream := loggingInterceptors(srv.options.logger, srv.options.logging)
+
+		builtin = append(builtin, namedInterceptor{stage: StageLogging, name: "logging", unary: unary, stream: stream})
+
+		if srv.options.payloadLogging == nil && slices.Contains(srv.options.logging.Events, LogEventPayload) {
+			srv.options.payloadLogging = &PayloadLoggingConfig{}
+		}
+
+		if srv.options.payloadLogging != nil {
+			l := payloadLogger{
+				logger: srv.options.logger,
+				config: *srv.options.payloadLogging,
+			}
+
+			builtin = append(builtin, namedInterceptor{
+				stage:  StageLogging,
+				name:   "payload-logging",
+				unary:  l.unaryServerInterceptor(),
+				stream: l.streamServerInterceptor(),
+			})
+		}
+	}
+
+	for _, observer := range srv.options.observers {
+		builtin = append(builtin, namedInterceptor{
+			stage:  StageMetrics,
+			name:   fmt.Sprintf("%T", observer),
+			unary:  observer.UnaryServerInterceptor(),
+			stream: observer.StreamServerInterceptor(),
+		})
+	}
+
+	if limiter := srv.options.limiter; limiter != nil {
+		i := namedInterceptor{
+			stage: StageRateLimit,
+			name:  fmt.Sprintf("%T", limiter),
+			unary: limiter.UnaryServerInterceptor(),
+		}
+
+		if sl, ok := limiter.(GRPCStreamRateLimiter); ok {
+			i.stream = sl.StreamServerInterceptor()
+		}
+
+		builtin = append(builtin, i)
+	}
+
+	srv.chain = interceptorChain(builtin, srv.options.interceptors)
+
+	var (
+		unaries []grpc.UnaryServerInterceptor
+		streams []grpc.StreamServerInterceptor
+	)
+
+	for _, i := range srv.chain {
+		if i.unary != nil {
+			unaries = append(unaries, i.unary)
+		}
+
+		if i.stream != nil {
+			streams = append(streams, i.stream)
+		}
+	}
+
+	// The stages run first, in a fixed order regardless of the options order.
+	srv.options.serverOpts = append(
+		[]grpc.ServerOption{
+			grpc.ChainUnaryInterceptor(unaries...),
+			grpc.ChainStreamInterceptor(streams...),
+		},
+		srv.options.serverOpts...,
+	)
+
 	// Init GRPC Server.
 	grpcSrv := grpc.NewServer(srv.options.serverOpts...)
 
@@ -184,6 +262,8 @@ func NewGRPC(config Config, opts ...Option) *GRPC {
 		register(grpcSrv)
 	}
 
+	services := registeredServices(grpcSrv)
+
 	// Make the service reflective so that APIs can be discovered.
 	if srv.options.reflection {
 		reflection.Register(grpcSrv)
@@ -191,16 +271,18 @@ func NewGRPC(config Config, opts ...Option) *GRPC {
 
 	// Init GRPC Health Server.
 	if srv.options.healthCheck {
-		grpcHealthServer := health.NewServer()
-		grpcHealthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
-		grpcHealthServer.SetServingStatus(config.Name, healthpb.HealthCheckResponse_SERVING)
-		healthpb.RegisterHealthServer(grpcSrv, grpcHealthServer)
+		grpcHealth := newGRPCHealth(append([]string{"", config.Name}, services...))
+		healthpb.RegisterHealthServer(grpcSrv, grpcHealth)
 
-		srv.grpcHealthServer = grpcHealthServer
+		srv.health = grpcHealth
 	}
 
 	srv.grpcServer = grpcSrv
 
+	if srv.options.adminServices {
+		srv.registerAdminServices()
+	}
+
 	return srv
 }
 
@@ -208,14 +290,24 @@ type grpcOptions struct {
 	registerServices []GRPCRegisterServiceFunc
 	serverOpts       []grpc.ServerOption
 
-	reflection bool
+	reflection     bool
+	maxRecvMsgSize int
+
+	observers []GRPCObserver
+	logger    ctxd.Logger
 
-	observer GRPCObserver
-	logger   ctxd.Logger
+	logging        GRPCLoggingConfig
+	payloadLogging *PayloadLoggingConfig
 
 	limiter GRPCRateLimiter
 
-	healthCheck bool
+	interceptors []namedInterceptor
+
+	healthCheck   bool
+	adminServices bool
+
+	// errs are the errors of the misused options, returned by Start.
+	errs []error
 }
 
 // GRPC is a listening grpc server instance.
@@ -223,13 +315,31 @@ type GRPC struct {
 	*Server
 
 	options grpcOptions
+	chain   []namedInterceptor
+
+	grpcServer   *grpc.Server
+	health       *GRPCHealth
+	adminCleanup func()
+
+	inProcessOnce sync.Once
+	inProcess     inProcess
+}
+
+// maxRecvMsgSize returns the max message size the grpc server can receive.
+func (srv *GRPC) maxRecvMsgSize() int {
+	if srv.options.maxRecvMsgSize > 0 {
+		return srv.options.maxRecvMsgSize
+	}
 
-	grpcServer       *grpc.Server
-	grpcHealthServer *health.Server
+	return defaultGRPCMaxRecvMsgSize
 }
 
 // Start starts serving the GRPC server.
 func (srv *GRPC) Start() error {
+	if len(srv.options.errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrGRPCStart, errors.Join(srv.options.errs...))
+	}
+
 	if err := srv.Server.Start(); err != nil {
 		return err
 	}
@@ -243,7 +353,22 @@ func (srv *GRPC) Start() error {
 
 // Stop gracefully shuts down the GRPC server.
 func (srv *GRPC) Stop() {
+	if srv.health != nil {
+		srv.health.stop()
+	}
+
 	srv.grpcServer.GracefulStop()
 
+	// The in-process connection can not be created once stopped.
+	srv.inProcessOnce.Do(func() { srv.inProcess.err = grpc.ErrServerStopped })
+
+	if srv.inProcess.conn != nil {
+		_ = srv.inProcess.conn.Close() //nolint:errcheck
+	}
+
+	if srv.adminCleanup != nil {
+		srv.adminCleanup()
+	}
+
 	srv.Server.Stop()
 }
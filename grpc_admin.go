@@ -0,0 +1,59 @@
+package servers
+
+import (
+	"fmt"
+	"slices"
+
+	"google.golang.org/grpc/admin"
+)
+
+// WithAdminServices registers the gRPC admin services, channelz and, when the xDS support is linked in, CSDS,
+// from google.golang.org/grpc/admin. They go through the same interceptor chain as the application services,
+// so they are protected by the StageAuth interceptors.
+//
+// To restrict them to a separate port, use NewAdminGRPC instead.
+// Apply to GRPC server instances.
+func WithAdminServices() Option {
+	return func(srv any) {
+		s, ok := srv.(*GRPC)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		s.options.adminServices = true
+	}
+}
+
+// NewAdminGRPC initiates a new wrapped grpc server serving only the gRPC admin services, see WithAdminServices.
+// The options, e.g. the StageAuth interceptors, apply as in NewGRPC, except WithRegisterService: the application
+// services are not registered on the admin server.
+func NewAdminGRPC(config Config, opts ...Option) *GRPC {
+	return NewGRPC(config, slices.Concat(opts, []Option{WithAdminServices(), withoutRegisterServices()})...)
+}
+
+// withoutRegisterServices drops the services set with WithRegisterService.
+func withoutRegisterServices() Option {
+	return func(srv any) {
+		s, ok := srv.(*GRPC)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		s.options.registerServices = nil
+	}
+}
+
+// registerAdminServices registers the admin services, keeping the cleanup to run on Stop.
+// The registration error is returned by Start.
+func (srv *GRPC) registerAdminServices() {
+	cleanup, err := admin.Register(srv.grpcServer)
+	if err != nil {
+		srv.options.errs = append(srv.options.errs, fmt.Errorf("register grpc admin services: %w", err))
+
+		return
+	}
+
+	srv.adminCleanup = cleanup
+}
@@ -0,0 +1,60 @@
+package servers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewAdminGRPC(t *testing.T) {
+	auth := func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if len(md.Get("authorization")) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "missing authorization")
+		}
+
+		return handler(ctx, req)
+	}
+
+	srv := servers.NewAdminGRPC(
+		servers.Config{Name: "Admin service"},
+		servers.WithAddrAssigned(),
+		servers.WithStageInterceptor(servers.StageAuth, "auth", auth, nil),
+		servers.WithRegisterService(newGRPCTestServer(ctxd.NoOpLogger{})),
+	)
+
+	go srv.Start() //nolint:errcheck
+
+	defer srv.Stop()
+
+	conn := dialGRPC(t, <-srv.AddrAssigned)
+	c := channelzpb.NewChannelzClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := c.GetServers(ctx, &channelzpb.GetServersRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	res, err := c.GetServers(metadata.AppendToOutgoingContext(ctx, "authorization", "token"), &channelzpb.GetServersRequest{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, res.GetServer())
+
+	// The application services are not served on the admin server.
+	_, err = testdata.NewGreeterClient(conn).SayHello(
+		metadata.AppendToOutgoingContext(ctx, "authorization", "token"),
+		&testdata.HelloRequest{Name: "test"},
+	)
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
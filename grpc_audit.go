@@ -0,0 +1,384 @@
+package servers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrAuditSinkFull is returned when the audit event can not be delivered because the sink is full.
+var ErrAuditSinkFull = errors.New("audit sink full")
+
+// AuditEvent is the structured record of a gRPC call, emitted once the call finished.
+type AuditEvent struct {
+	Time       time.Time       `json:"time"`
+	Principal  string          `json:"principal,omitempty"`
+	ClientID   string          `json:"client_id,omitempty"`
+	Peer       string          `json:"peer,omitempty"`
+	FullMethod string          `json:"full_method"`
+	Code       string          `json:"code"`
+	ErrorID    string          `json:"error_id,omitempty"`
+	Latency    time.Duration   `json:"latency"`
+	Request    json.RawMessage `json:"request,omitempty"`
+}
+
+// AuditSink is the destination of the audit events.
+type AuditSink interface {
+	Audit(ctx context.Context, event AuditEvent) error
+}
+
+// AuditSinkFunc is the function adapter of AuditSink.
+type AuditSinkFunc func(ctx context.Context, event AuditEvent) error
+
+// Audit implements AuditSink.
+func (f AuditSinkFunc) Audit(ctx context.Context, event AuditEvent) error {
+	return f(ctx, event)
+}
+
+// NewLoggerAuditSink creates an AuditSink that writes the events to the logger with info level.
+func NewLoggerAuditSink(logger ctxd.Logger) AuditSink {
+	return AuditSinkFunc(func(ctx context.Context, event AuditEvent) error {
+		kvs := []any{
+			"audit.principal", event.Principal,
+			"audit.client_id", event.ClientID,
+			"audit.peer", event.Peer,
+			"audit.full_method", event.FullMethod,
+			"audit.code", event.Code,
+			"audit.latency", event.Latency.String(),
+		}
+
+		if event.ErrorID != "" {
+			kvs = append(kvs, "audit.error_id", event.ErrorID)
+		}
+
+		if event.Request != nil {
+			kvs = append(kvs, "audit.request", string(event.Request))
+		}
+
+		logger.Info(ctx, "audit", kvs...)
+
+		return nil
+	})
+}
+
+// NewChanAuditSink creates an AuditSink that sends the events to the channel.
+// The send does not block, ErrAuditSinkFull is returned when the channel is not ready to receive.
+func NewChanAuditSink(ch chan<- AuditEvent) AuditSink {
+	return AuditSinkFunc(func(_ context.Context, event AuditEvent) error {
+		select {
+		case ch <- event:
+			return nil
+		default:
+			return ErrAuditSinkFull
+		}
+	})
+}
+
+// FileAuditSinkConfig contains configuration options for a FileAuditSink.
+type FileAuditSinkConfig struct {
+	// Path is the file the events are written to.
+	Path string `envconfig:"PATH" required:"true"`
+	// MaxSize is the size in bytes the file can grow to before being rotated. Zero disables rotation.
+	MaxSize int64 `envconfig:"MAX_SIZE"`
+	// MaxBackups is the number of rotated files to retain, named Path.1 (newest) to Path.N (oldest), 3 by default.
+	// Negative keeps no backup, the file is truncated on rotation.
+	MaxBackups int `envconfig:"MAX_BACKUPS" default:"3"`
+}
+
+// defaultAuditMaxBackups is the number of rotated audit files retained when not configured.
+const defaultAuditMaxBackups = 3
+
+// FileAuditSink is an AuditSink writing the events as JSON lines to a file, rotating it by size.
+// When the rotation fails, the event is written to the current file and the rotation error is returned.
+type FileAuditSink struct {
+	config FileAuditSinkConfig
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewFileAuditSink creates a FileAuditSink, opening (or creating) the file in append mode.
+func NewFileAuditSink(config FileAuditSinkConfig) (*FileAuditSink, error) {
+	if config.MaxBackups == 0 {
+		config.MaxBackups = defaultAuditMaxBackups
+	}
+
+	s := &FileAuditSink{config: config}
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileAuditSink) open() error {
+	f, err := os.OpenFile(s.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close() //nolint:errcheck
+
+		return fmt.Errorf("stat audit file: %w", err)
+	}
+
+	s.f = f
+	s.size = info.Size()
+
+	return nil
+}
+
+// rotate moves the file to the first backup and opens a new one, or truncates it when there are no backups.
+// The current file is kept open until the new one is opened, so the events are still written when it fails.
+func (s *FileAuditSink) rotate() error {
+	if s.config.MaxBackups <= 0 {
+		if err := s.f.Truncate(0); err != nil {
+			return fmt.Errorf("truncate audit file: %w", err)
+		}
+
+		s.size = 0
+
+		return nil
+	}
+
+	// The file is already moved when the previous rotation failed to open the new one.
+	if _, err := os.Stat(s.config.Path); err == nil {
+		_ = os.Remove(fmt.Sprintf("%s.%d", s.config.Path, s.config.MaxBackups)) //nolint:errcheck
+
+		for i := s.config.MaxBackups - 1; i > 0; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", s.config.Path, i), fmt.Sprintf("%s.%d", s.config.Path, i+1)) //nolint:errcheck
+		}
+
+		if err := os.Rename(s.config.Path, s.config.Path+".1"); err != nil {
+			return fmt.Errorf("rotate audit file: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("rotate audit file: %w", err)
+	}
+
+	prev := s.f
+
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	if err := prev.Close(); err != nil {
+		return fmt.Errorf("close audit file: %w", err)
+	}
+
+	return nil
+}
+
+// Audit implements AuditSink.
+func (s *FileAuditSink) Audit(_ context.Context, event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal audit event: %w", err)
+	}
+
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return fmt.Errorf("write audit event: %w", os.ErrClosed)
+	}
+
+	var rotateErr error
+
+	// A failed rotation is retried on the next event, the event is written to the current file.
+	if s.config.MaxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.config.MaxSize {
+		rotateErr = s.rotate()
+	}
+
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+
+	if err != nil {
+		return fmt.Errorf("write audit event: %w", err)
+	}
+
+	return rotateErr
+}
+
+// Close closes the underlying file.
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+
+	err := s.f.Close()
+	s.f = nil
+
+	return err
+}
+
+// AuditRule decides whether the calls to the matching methods are audited.
+type AuditRule struct {
+	// Methods are the full method glob patterns the rule applies to, e.g. "/helloworld.Greeter/*".
+	Methods []string
+	// Exclude skips auditing the matching methods.
+	Exclude bool
+	// IncludeRequest adds the request, rendered as JSON, to the event of unary calls.
+	IncludeRequest bool
+	// RedactFields are the request field paths masked when IncludeRequest is set, e.g. "user.password".
+	RedactFields []string
+}
+
+// AuditConfig contains configuration options for an Auditor.
+type AuditConfig struct {
+	// Rules are evaluated in order and the first rule matching the method applies.
+	// When no rule is configured every method is audited, otherwise methods no rule matches are not audited.
+	Rules []AuditRule
+	// OnError is called when the sink fails to record an event. Errors are ignored when nil.
+	OnError func(ctx context.Context, err error)
+	// KeyExtractor identifies the client of the call. Defaults to the "client-id" metadata key.
+	KeyExtractor ClientKeyExtractor
+}
+
+// Auditor is a GRPCObserver that emits an AuditEvent per audited gRPC call to an AuditSink.
+type Auditor struct {
+	sink   AuditSink
+	config AuditConfig
+}
+
+// NewAuditor creates a new Auditor writing to the sink.
+func NewAuditor(sink AuditSink, config AuditConfig) *Auditor {
+	if config.KeyExtractor == nil {
+		config.KeyExtractor = MetadataKeyExtractor("client-id")
+	}
+
+	return &Auditor{
+		sink:   sink,
+		config: config,
+	}
+}
+
+func (a *Auditor) rule(fullMethod string) (AuditRule, bool) {
+	if len(a.config.Rules) == 0 {
+		return AuditRule{}, true
+	}
+
+	for _, r := range a.config.Rules {
+		if matchFullMethod(r.Methods, fullMethod) {
+			return r, !r.Exclude
+		}
+	}
+
+	return AuditRule{}, false
+}
+
+// audit emits the event of the call. The principal is the one set while handling the call, see
+// ContextWithPrincipal, or the one ctx already carried.
+func (a *Auditor) audit(
+	ctx context.Context,
+	principal *principalRecorder,
+	rule AuditRule,
+	fullMethod string,
+	req any,
+	start time.Time,
+	err error,
+) {
+	st := status.Convert(err)
+
+	event := AuditEvent{
+		Time:       start,
+		FullMethod: fullMethod,
+		Code:       st.Code().String(),
+		ErrorID:    errorIDFromStatus(st),
+		Latency:    time.Since(start),
+	}
+
+	if p, ok := principal.get(); ok {
+		event.Principal = p
+		// The client may be identified by the principal, which ctx does not carry when set while handling the call.
+		ctx = context.WithValue(ctx, principalCtxKey{}, p)
+	} else if p, ok := PrincipalFromContext(ctx); ok {
+		event.Principal = p
+	}
+
+	clientID, ok := a.config.KeyExtractor.ClientKey(ctx)
+	if !ok {
+		clientID = unknownClientID
+	}
+
+	event.ClientID = clientID
+
+	if p, ok := peer.FromContext(ctx); ok {
+		event.Peer = p.Addr.String()
+	}
+
+	if rule.IncludeRequest {
+		if msg, ok := req.(proto.Message); ok {
+			event.Request = marshalPayload(redactMessage(msg, rule.RedactFields))
+		}
+	}
+
+	if err := a.sink.Audit(ctx, event); err != nil && a.config.OnError != nil {
+		a.config.OnError(ctx, err)
+	}
+}
+
+// UnaryServerInterceptor returns a new unary server interceptor that audits the calls.
+func (a *Auditor) UnaryServerInterceptor() func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		rule, ok := a.rule(info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		ctx, principal := contextWithPrincipalRecorder(ctx)
+
+		resp, err := handler(ctx, req)
+
+		a.audit(ctx, principal, rule, info.FullMethod, req, start, err)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a new stream server interceptor that audits the calls once the stream ends.
+func (a *Auditor) StreamServerInterceptor() func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		rule, ok := a.rule(info.FullMethod)
+		if !ok {
+			return handler(srv, ss)
+		}
+
+		start := time.Now()
+		ctx, principal := contextWithPrincipalRecorder(ss.Context())
+
+		err := handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+
+		a.audit(ctx, principal, rule, info.FullMethod, nil, start, err)
+
+		return err
+	}
+}
+
+// WithAuditor sets the Auditor, used to append UnaryServerInterceptor and StreamServerInterceptor before StageAuth,
+// so the calls rejected by the authentication and the rate limiter are audited. The principal set by the
+// authentication with ContextWithPrincipal is recorded, even when the call fails afterwards.
+// Apply to GRPC server instances.
+func WithAuditor(auditor *Auditor) Option {
+	return WithInterceptorBefore(StageAuth, "audit", auditor.UnaryServerInterceptor(), auditor.StreamServerInterceptor())
+}
@@ -0,0 +1,222 @@
+package servers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPC_StartServing_WithAuditor(t *testing.T) {
+	events := make(chan servers.AuditEvent, 1)
+
+	auditor := servers.NewAuditor(servers.NewChanAuditSink(events), servers.AuditConfig{
+		Rules: []servers.AuditRule{
+			{
+				Methods:        []string{"/helloworld.Greeter/*"},
+				IncludeRequest: true,
+				RedactFields:   []string{"name"},
+			},
+		},
+	})
+
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithAuditor(auditor))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	// Set up a connection to the server.
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "grpc connection: %v", err)
+
+	defer conn.Close() //nolint:errcheck
+
+	c := testdata.NewGreeterClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "client-id", "test-client")
+
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "secret"})
+	require.NoErrorf(t, err, "SayHello request: %v", err)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, "/helloworld.Greeter/SayHello", event.FullMethod)
+		assert.Equal(t, "OK", event.Code)
+		assert.Equal(t, "test-client", event.ClientID)
+		assert.NotEmpty(t, event.Peer)
+		assert.JSONEq(t, `{"name":"[REDACTED]"}`, string(event.Request))
+	case <-ctx.Done():
+		t.Fatal("audit event not received")
+	}
+}
+
+func TestGRPC_StartServing_WithAuditor_authRejected(t *testing.T) {
+	events := make(chan servers.AuditEvent, 1)
+
+	auth := func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		switch token := md.Get("authorization"); {
+		case len(token) == 0:
+			return nil, status.Error(codes.Unauthenticated, "missing token")
+		case token[0] != "admin":
+			// The principal is known, but not allowed.
+			return nil, status.Error(codes.PermissionDenied, "not allowed")
+		}
+
+		return handler(servers.ContextWithPrincipal(ctx, "admin"), req)
+	}
+
+	srv, addr, err := startGRPCService(nil, nil, nil,
+		servers.WithAuditor(servers.NewAuditor(servers.NewChanAuditSink(events), servers.AuditConfig{
+			KeyExtractor: servers.PrincipalKeyExtractor(),
+		})),
+		servers.WithStageInterceptor(servers.StageAuth, "auth", auth, nil),
+	)
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewGreeterClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for token, code := range map[string]codes.Code{"": codes.Unauthenticated, "guest": codes.PermissionDenied, "admin": codes.OK} {
+		callCtx := ctx
+		if token != "" {
+			callCtx = metadata.AppendToOutgoingContext(ctx, "authorization", token)
+		}
+
+		_, err = c.SayHello(callCtx, &testdata.HelloRequest{Name: "test"})
+		require.Equal(t, code, status.Code(err))
+
+		select {
+		case event := <-events:
+			assert.Equal(t, code.String(), event.Code)
+
+			if code == codes.OK {
+				assert.Equal(t, "admin", event.Principal)
+				assert.Equal(t, "admin", event.ClientID)
+			} else {
+				assert.Equal(t, "unknown-client", event.ClientID)
+			}
+		case <-ctx.Done():
+			t.Fatalf("audit event of %s call not received", code)
+		}
+	}
+}
+
+func TestFileAuditSink_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := servers.NewFileAuditSink(servers.FileAuditSinkConfig{
+		Path:       path,
+		MaxSize:    64,
+		MaxBackups: 1,
+	})
+	require.NoError(t, err)
+
+	defer sink.Close() //nolint:errcheck
+
+	for _, m := range []string{"/a.A/One", "/a.A/Two", "/a.A/Three"} {
+		require.NoError(t, sink.Audit(context.Background(), servers.AuditEvent{FullMethod: m, Code: "OK"}))
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+
+	defer f.Close() //nolint:errcheck
+
+	scanner := bufio.NewScanner(f)
+	require.True(t, scanner.Scan())
+
+	var event servers.AuditEvent
+
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+	assert.Equal(t, "/a.A/Three", event.FullMethod)
+
+	_, err = os.Stat(path + ".1")
+	require.NoError(t, err, "backup file not found")
+
+	_, err = os.Stat(path + ".2")
+	assert.True(t, os.IsNotExist(err), "unexpected second backup file")
+}
+
+func TestFileAuditSink_Rotate_defaultBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	// The backups are kept by default, the audit trail is not truncated.
+	sink, err := servers.NewFileAuditSink(servers.FileAuditSinkConfig{
+		Path:    path,
+		MaxSize: 64,
+	})
+	require.NoError(t, err)
+
+	defer sink.Close() //nolint:errcheck
+
+	for _, m := range []string{"/a.A/One", "/a.A/Two", "/a.A/Three", "/a.A/Four", "/a.A/Five"} {
+		require.NoError(t, sink.Audit(context.Background(), servers.AuditEvent{FullMethod: m, Code: "OK"}))
+	}
+
+	for _, backup := range []string{".1", ".2", ".3"} {
+		_, err = os.Stat(path + backup)
+		require.NoError(t, err, "backup file %s not found", backup)
+	}
+
+	_, err = os.Stat(path + ".4")
+	assert.True(t, os.IsNotExist(err), "unexpected fourth backup file")
+}
+
+func TestFileAuditSink_RotateFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := servers.NewFileAuditSink(servers.FileAuditSinkConfig{
+		Path:       path,
+		MaxSize:    64,
+		MaxBackups: 1,
+	})
+	require.NoError(t, err)
+
+	defer sink.Close() //nolint:errcheck
+
+	// A non-empty directory in place of the backup fails the rotation.
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".1", "dir"), 0o700))
+
+	audit := func(m string) error {
+		return sink.Audit(context.Background(), servers.AuditEvent{FullMethod: m, Code: "OK"})
+	}
+
+	require.NoError(t, audit("/a.A/One"))
+	require.Error(t, audit("/a.A/Two"))
+
+	// The sink keeps writing to the current file, and rotates once the backup can be written.
+	require.NoError(t, os.RemoveAll(path+".1"))
+	require.NoError(t, audit("/a.A/Three"))
+
+	backup, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.Contains(t, string(backup), "/a.A/One")
+	assert.Contains(t, string(backup), "/a.A/Two")
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(current), "/a.A/Three")
+	assert.NotContains(t, string(current), "/a.A/Two")
+}
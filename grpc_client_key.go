@@ -0,0 +1,158 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ClientKeyExtractor extracts the key identifying the client of a gRPC call.
+type ClientKeyExtractor interface {
+	// ClientKey returns the key of the client, reporting false when the client can not be identified.
+	ClientKey(ctx context.Context) (string, bool)
+}
+
+// ClientKeyExtractorFunc is the function adapter of ClientKeyExtractor.
+type ClientKeyExtractorFunc func(ctx context.Context) (string, bool)
+
+// ClientKey implements ClientKeyExtractor.
+func (f ClientKeyExtractorFunc) ClientKey(ctx context.Context) (string, bool) {
+	return f(ctx)
+}
+
+// MetadataKeyExtractor identifies the client by the first value of the metadata key, e.g. "client-id".
+func MetadataKeyExtractor(key string) ClientKeyExtractor {
+	key = strings.ToLower(key)
+
+	return ClientKeyExtractorFunc(func(ctx context.Context) (string, bool) {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return "", false
+		}
+
+		if vals := md.Get(key); len(vals) > 0 && vals[0] != "" {
+			return vals[0], true
+		}
+
+		return "", false
+	})
+}
+
+// PrincipalKeyExtractor identifies the client by the authenticated principal, see ContextWithPrincipal.
+func PrincipalKeyExtractor() ClientKeyExtractor {
+	return ClientKeyExtractorFunc(PrincipalFromContext)
+}
+
+// PeerIPKeyExtractor identifies the client by its IP address.
+//
+// When the peer address belongs to one of the trusted proxies CIDRs, e.g. the GRPCRest gateway, or the call is
+// made through the in-process connection, see GRPC.ClientConn, the client IP is
+// taken from the x-forwarded-for metadata, set by the gateway from the X-Forwarded-For header. The addresses in
+// x-forwarded-for are walked from right to left, and the first one not belonging to a trusted proxy is the client.
+func PeerIPKeyExtractor(trustedProxies ...string) (ClientKeyExtractor, error) {
+	prefixes := make([]netip.Prefix, 0, len(trustedProxies))
+
+	for _, cidr := range trustedProxies {
+		p, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("parse trusted proxy %q: %w", cidr, err)
+		}
+
+		prefixes = append(prefixes, p.Masked())
+	}
+
+	trusted := func(addr netip.Addr) bool {
+		for _, p := range prefixes {
+			if p.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return ClientKeyExtractorFunc(func(ctx context.Context) (string, bool) {
+		p, ok := peer.FromContext(ctx)
+		if !ok || p.Addr == nil {
+			return "", false
+		}
+
+		var addr netip.Addr
+
+		// The in-process gateway, see WithInProcessGRPC, is a trusted proxy.
+		if p.Addr.Network() != inProcessNetwork {
+			host, _, err := net.SplitHostPort(p.Addr.String())
+			if err != nil {
+				host = p.Addr.String()
+			}
+
+			addr, err = netip.ParseAddr(host)
+			if err != nil {
+				return host, host != ""
+			}
+
+			if !trusted(addr) {
+				return addr.Unmap().String(), true
+			}
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		var forwarded []string
+
+		for _, v := range md.Get("x-forwarded-for") {
+			for _, ip := range strings.Split(v, ",") {
+				forwarded = append(forwarded, strings.TrimSpace(ip))
+			}
+		}
+
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			fAddr, err := netip.ParseAddr(forwarded[i])
+			if err != nil {
+				// Not an IP address, the chain can not be trusted from here on.
+				break
+			}
+
+			addr = fAddr
+
+			if !trusted(fAddr) {
+				break
+			}
+		}
+
+		if !addr.IsValid() {
+			// In-process call not forwarded by the gateway.
+			return "", false
+		}
+
+		return addr.Unmap().String(), true
+	}), nil
+}
+
+// CompositeKeyExtractor identifies the client by the keys of all the extractors, joined by "|" in the extractors
+// order. Extractors not identifying the client leave their part empty, so the keys of different extractors do not
+// collide, e.g. "john|" and "|john". The client is unidentified when none identifies it.
+func CompositeKeyExtractor(extractors ...ClientKeyExtractor) ClientKeyExtractor {
+	return ClientKeyExtractorFunc(func(ctx context.Context) (string, bool) {
+		keys := make([]string, len(extractors))
+		identified := false
+
+		for i, e := range extractors {
+			if k, ok := e.ClientKey(ctx); ok {
+				keys[i] = k
+				identified = true
+			}
+		}
+
+		if !identified {
+			return "", false
+		}
+
+		return strings.Join(keys, "|"), true
+	})
+}
@@ -0,0 +1,160 @@
+package servers_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/serverstest"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func peerContext(addr string, md ...string) context.Context {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: tcpAddr})
+
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(md...))
+}
+
+func TestPeerIPKeyExtractor(t *testing.T) {
+	extractor, err := servers.PeerIPKeyExtractor("10.0.0.0/8", "127.0.0.1/32")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context //nolint:containedctx
+		want string
+	}{
+		{
+			name: "untrusted peer",
+			ctx:  peerContext("192.168.1.10:5000", "x-forwarded-for", "1.2.3.4"),
+			want: "192.168.1.10",
+		},
+		{
+			name: "trusted peer without forwarded for",
+			ctx:  peerContext("127.0.0.1:5000"),
+			want: "127.0.0.1",
+		},
+		{
+			name: "trusted peer",
+			ctx:  peerContext("127.0.0.1:5000", "x-forwarded-for", "1.2.3.4, 10.0.0.2"),
+			want: "1.2.3.4",
+		},
+		{
+			name: "spoofed forwarded for",
+			ctx:  peerContext("127.0.0.1:5000", "x-forwarded-for", "6.6.6.6, 1.2.3.4"),
+			want: "1.2.3.4",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			key, ok := extractor.ClientKey(tc.ctx)
+			require.True(t, ok)
+			assert.Equal(t, tc.want, key)
+		})
+	}
+
+	_, err = servers.PeerIPKeyExtractor("not a cidr")
+	require.Error(t, err)
+}
+
+func TestCompositeKeyExtractor(t *testing.T) {
+	extractor := servers.CompositeKeyExtractor(
+		servers.PrincipalKeyExtractor(),
+		servers.MetadataKeyExtractor("X-Tenant"),
+	)
+
+	ctx := servers.ContextWithPrincipal(peerContext("127.0.0.1:5000", "x-tenant", "acme"), "john")
+
+	key, ok := extractor.ClientKey(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "john|acme", key)
+
+	// The missing parts keep their slot, the keys of the extractors do not collide.
+	key, ok = extractor.ClientKey(servers.ContextWithPrincipal(context.Background(), "acme"))
+	require.True(t, ok)
+	assert.Equal(t, "acme|", key)
+
+	key, ok = extractor.ClientKey(peerContext("127.0.0.1:5000", "x-tenant", "acme"))
+	require.True(t, ok)
+	assert.Equal(t, "|acme", key)
+
+	_, ok = extractor.ClientKey(context.Background())
+	assert.False(t, ok)
+}
+
+func TestPerClientRateLimiter_Unidentified(t *testing.T) {
+	limiter := servers.NewPerClientRateLimiter(10, 10, servers.WithUnidentifiedRate(0, 0))
+
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(limiter))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewGreeterClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	_, err = c.SayHello(metadata.AppendToOutgoingContext(ctx, "client-id", "test-client"), &testdata.HelloRequest{Name: "test"})
+	require.NoError(t, err)
+}
+
+func TestPeerIPKeyExtractor_inProcessGateway(t *testing.T) {
+	extractor, err := servers.PeerIPKeyExtractor("127.0.0.0/8")
+	require.NoError(t, err)
+
+	limiter := servers.NewPerClientRateLimiter(0.001, 1,
+		servers.WithKeyExtractor(extractor),
+		servers.WithUnidentifiedRate(0, 0),
+	)
+
+	s := serverstest.Start(t,
+		serverstest.WithGRPC(
+			servers.WithRegisterService(newGRPCTestServer(ctxd.NoOpLogger{})),
+			servers.WithGRPCRateLimiter(limiter),
+		),
+		serverstest.WithGateway(gRPCRestConnTestServer{}),
+	)
+
+	sayHello := func(forwardedFor string) int {
+		req, err := http.NewRequest(http.MethodGet, s.GRPCRestURL+"/say/test", nil)
+		require.NoError(t, err)
+
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		return res.StatusCode
+	}
+
+	// The in-process gateway is trusted, the clients are identified by the forwarded addresses.
+	assert.Equal(t, http.StatusOK, sayHello("203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, sayHello("203.0.113.1"))
+	assert.Equal(t, http.StatusOK, sayHello("203.0.113.2"))
+
+	// The in-process calls not forwarded by the gateway are unidentified.
+	conn, err := s.GRPC.ClientConn()
+	require.NoError(t, err)
+
+	_, err = testdata.NewGreeterClient(conn).SayHello(context.Background(), &testdata.HelloRequest{Name: "test"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
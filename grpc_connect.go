@@ -0,0 +1,603 @@
+package servers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Connect envelope flags.
+const (
+	connectFlagCompressed = 0x01
+	connectFlagEndStream  = 0x02
+)
+
+// connectCodes maps the gRPC codes to the Connect error codes and the HTTP status of the unary errors.
+var connectCodes = map[codes.Code]connectCode{
+	codes.Canceled:           {"canceled", 499},
+	codes.Unknown:            {"unknown", http.StatusInternalServerError},
+	codes.InvalidArgument:    {"invalid_argument", http.StatusBadRequest},
+	codes.DeadlineExceeded:   {"deadline_exceeded", http.StatusGatewayTimeout},
+	codes.NotFound:           {"not_found", http.StatusNotFound},
+	codes.AlreadyExists:      {"already_exists", http.StatusConflict},
+	codes.PermissionDenied:   {"permission_denied", http.StatusForbidden},
+	codes.ResourceExhausted:  {"resource_exhausted", http.StatusTooManyRequests},
+	codes.FailedPrecondition: {"failed_precondition", http.StatusBadRequest},
+	codes.Aborted:            {"aborted", http.StatusConflict},
+	codes.OutOfRange:         {"out_of_range", http.StatusBadRequest},
+	codes.Unimplemented:      {"unimplemented", http.StatusNotImplemented},
+	codes.Internal:           {"internal", http.StatusInternalServerError},
+	codes.Unavailable:        {"unavailable", http.StatusServiceUnavailable},
+	codes.DataLoss:           {"data_loss", http.StatusInternalServerError},
+	codes.Unauthenticated:    {"unauthenticated", http.StatusUnauthorized},
+}
+
+type connectCode struct {
+	name       string
+	httpStatus int
+}
+
+// connectCodeOf returns the Connect error code of the gRPC code, unknown for the codes without one.
+func connectCodeOf(c codes.Code) connectCode {
+	if cc, ok := connectCodes[c]; ok {
+		return cc
+	}
+
+	return connectCodes[codes.Unknown]
+}
+
+// connectError is the Connect error JSON.
+type connectError struct {
+	Code    string               `json:"code"`
+	Message string               `json:"message,omitempty"`
+	Details []connectErrorDetail `json:"details,omitempty"`
+}
+
+type connectErrorDetail struct {
+	Type  string          `json:"type"`
+	Value string          `json:"value"`
+	Debug json.RawMessage `json:"debug,omitempty"`
+}
+
+// newConnectError returns the Connect error JSON of the status, the details carry their JSON form as debug.
+func newConnectError(st *status.Status) connectError {
+	e := connectError{
+		Code:    connectCodeOf(st.Code()).name,
+		Message: st.Message(),
+	}
+
+	for _, a := range st.Proto().GetDetails() {
+		d := connectErrorDetail{
+			Type:  strings.TrimPrefix(a.GetTypeUrl(), "type.googleapis.com/"),
+			Value: base64.RawStdEncoding.EncodeToString(a.GetValue()),
+		}
+
+		if m, err := a.UnmarshalNew(); err == nil {
+			d.Debug, _ = protojson.Marshal(m) //nolint:errcheck
+		}
+
+		e.Details = append(e.Details, d)
+	}
+
+	return e
+}
+
+// connectEndStream is the JSON of the Connect end of stream message.
+type connectEndStream struct {
+	Error    *connectError       `json:"error,omitempty"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+}
+
+// NewConnectHandler creates a handler serving the services of the GRPC server with the Connect protocol: unary
+// calls with JSON or proto bodies and streaming calls with envelopes. Calls go through the GRPC server, so they
+// run its interceptor chain. Messages are converted from and to JSON with the service descriptors.
+//
+// It can be served alone, e.g. with NewREST, or along the gateway with WithConnect.
+func NewConnectHandler(grpcSrv *GRPC) http.Handler {
+	return connectHandler{server: grpcSrv}
+}
+
+// WithConnect serves the services of the GRPC server with the Connect protocol along the gateway, see
+// NewConnectHandler. Connect calls are told apart by their path, a registered method, and content type.
+// Apply to GRPCRest server instances.
+func WithConnect(grpcSrv *GRPC) Option {
+	return func(srv any) {
+		s, ok := srv.(*GRPCRest)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		h := connectHandler{server: grpcSrv}
+
+		s.options.middlewares = append(s.options.middlewares, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if h.isConnectRequest(r) {
+					h.ServeHTTP(w, r)
+
+					return
+				}
+
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+}
+
+type connectHandler struct {
+	server *GRPC
+}
+
+// connectContentType returns the codec of the request and whether it is a streaming call.
+func connectContentType(r *http.Request) (string, bool, bool) {
+	ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", false, false
+	}
+
+	switch ct {
+	case "application/json", "application/proto":
+		return strings.TrimPrefix(ct, "application/"), false, true
+	case "application/connect+json", "application/connect+proto":
+		return strings.TrimPrefix(ct, "application/connect+"), true, true
+	default:
+		return "", false, false
+	}
+}
+
+func (h connectHandler) isConnectRequest(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+
+	if _, _, ok := connectContentType(r); !ok {
+		return false
+	}
+
+	_, ok := h.method(r.URL.Path)
+
+	return ok
+}
+
+// method returns the descriptor of the method of the path, when it is served by the GRPC server.
+func (h connectHandler) method(path string) (protoreflect.MethodDescriptor, bool) {
+	service, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+
+	if _, ok := h.server.grpcServer.GetServiceInfo()[service]; !ok {
+		return nil, false
+	}
+
+	return methodDescriptor(path)
+}
+
+func (h connectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	codec, streaming, ok := connectContentType(r)
+	if !ok || r.Method != http.MethodPost {
+		writeConnectError(w, status.New(codes.Unimplemented, "unsupported content type or method"), nil)
+
+		return
+	}
+
+	md, ok := h.method(r.URL.Path)
+	if !ok {
+		writeConnectError(w, status.New(codes.Unimplemented, "unknown method "+r.URL.Path), nil)
+
+		return
+	}
+
+	for _, enc := range []string{r.Header.Get("Content-Encoding"), r.Header.Get("Connect-Content-Encoding")} {
+		if enc != "" && enc != "identity" {
+			writeConnectError(w, status.New(codes.Unimplemented, "unsupported compression "+enc), nil)
+
+			return
+		}
+	}
+
+	c := connectCodec{
+		name:   codec,
+		input:  messageType(md.Input()),
+		output: messageType(md.Output()),
+		limit:  h.server.maxRecvMsgSize(),
+	}
+
+	rw := &connectResponseWriter{
+		w:         w,
+		header:    make(http.Header),
+		codec:     c,
+		streaming: streaming,
+	}
+
+	var body io.ReadCloser
+
+	if streaming {
+		// Responses are written while the request messages are still read.
+		_ = http.NewResponseController(w).EnableFullDuplex() //nolint:errcheck
+
+		pr, pw := io.Pipe()
+
+		go func() {
+			err := c.requestEnvelopes(r.Body, pw)
+
+			// The status of a rejected request message is the status of the call.
+			if st, ok := status.FromError(err); ok && err != nil {
+				rw.requestStatus.Store(st)
+			}
+
+			pw.CloseWithError(err)
+		}()
+
+		body = pr
+	} else {
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(c.limit)))
+		if err == nil {
+			data, err = c.toGRPC(data)
+		}
+
+		if err != nil {
+			code := codes.InvalidArgument
+
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				code = codes.ResourceExhausted
+			}
+
+			writeConnectError(w, status.New(code, err.Error()), nil)
+
+			return
+		}
+
+		body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	// The call is served by the grpc server as if it came through HTTP/2.
+	req := r.Clone(r.Context())
+	req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2"
+	req.Body = body
+	req.ContentLength = -1
+	req.Header.Del("Content-Length")
+	req.Header.Del("Connect-Protocol-Version")
+	req.Header.Set("Content-Type", "application/grpc")
+
+	if ms := req.Header.Get("Connect-Timeout-Ms"); ms != "" {
+		req.Header.Del("Connect-Timeout-Ms")
+		req.Header.Set("Grpc-Timeout", ms+"m")
+	}
+
+	h.server.grpcServer.ServeHTTP(rw, req)
+
+	rw.finish()
+}
+
+// connectCodec converts the messages between the Connect codec and the gRPC wire format.
+type connectCodec struct {
+	name   string
+	input  protoreflect.MessageType
+	output protoreflect.MessageType
+	// limit is the max size of a request message.
+	limit int
+}
+
+// toGRPC returns the gRPC frame of the request message.
+func (c connectCodec) toGRPC(data []byte) ([]byte, error) {
+	if c.name == "json" {
+		m := c.input.New().Interface()
+
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, m); err != nil {
+			return nil, err
+		}
+
+		var err error
+
+		if data, err = proto.Marshal(m); err != nil {
+			return nil, err
+		}
+	}
+
+	return appendEnvelope(nil, 0, data), nil
+}
+
+// fromGRPC returns the response message in the codec.
+func (c connectCodec) fromGRPC(data []byte) ([]byte, error) {
+	if c.name != "json" {
+		return data, nil
+	}
+
+	m := c.output.New().Interface()
+
+	if err := proto.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+
+	return protojson.Marshal(m)
+}
+
+// requestEnvelopes converts the request envelopes into gRPC frames.
+func (c connectCodec) requestEnvelopes(r io.Reader, w io.Writer) error {
+	for {
+		flags, data, err := readEnvelope(r, c.limit)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		if flags&connectFlagCompressed != 0 {
+			return status.Error(codes.Unimplemented, "compressed messages are not supported")
+		}
+
+		frame, err := c.toGRPC(data)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+	}
+}
+
+// readEnvelope reads a length prefixed message, failing with ResourceExhausted when it is larger than limit.
+func readEnvelope(r io.Reader, limit int) (byte, []byte, error) {
+	var prefix [5]byte
+
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return 0, nil, err
+	}
+
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if uint64(n) > uint64(limit) { //nolint:gosec
+		return 0, nil, status.Errorf(codes.ResourceExhausted, "message larger than max (%d vs. %d)", n, limit)
+	}
+
+	data := make([]byte, n)
+
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+
+	return prefix[0], data, nil
+}
+
+// appendEnvelope appends the length prefixed message.
+func appendEnvelope(b []byte, flags byte, data []byte) []byte {
+	b = append(b, flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(data))) //nolint:gosec
+
+	return append(b, data...)
+}
+
+// writeConnectError writes the unary error response.
+func writeConnectError(w http.ResponseWriter, st *status.Status, header http.Header) {
+	for k, vv := range header {
+		w.Header()[k] = vv
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(connectCodeOf(st.Code()).httpStatus)
+
+	_ = json.NewEncoder(w).Encode(newConnectError(st)) //nolint:errcheck
+}
+
+// connectResponseWriter translates the gRPC response written by the grpc server to Connect.
+type connectResponseWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	codec     connectCodec
+	streaming bool
+
+	// httpStatus is the status of the gRPC response, not OK when the grpc server rejects the request.
+	httpStatus int
+	metadata   http.Header
+	// buf holds the gRPC frames not fully written yet, messages the unary response ones.
+	buf      []byte
+	messages [][]byte
+	err      error
+
+	// requestStatus is the status of the request messages rejected while converting them.
+	requestStatus atomic.Pointer[status.Status]
+}
+
+func (rw *connectResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *connectResponseWriter) WriteHeader(code int) {
+	if rw.metadata != nil {
+		return
+	}
+
+	rw.httpStatus = code
+	rw.metadata = make(http.Header)
+
+	for k, vv := range rw.header {
+		if k == "Trailer" || k == "Content-Type" || k == "Date" || strings.HasPrefix(k, "Trailer:") ||
+			strings.HasPrefix(k, "Grpc-") {
+			continue
+		}
+
+		rw.metadata[k] = vv
+	}
+
+	// Headers set from now on are trailers.
+	rw.header = make(http.Header)
+
+	if rw.streaming && code == http.StatusOK {
+		for k, vv := range rw.metadata {
+			rw.w.Header()[k] = vv
+		}
+
+		rw.w.Header().Set("Content-Type", "application/connect+"+rw.codec.name)
+		rw.w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (rw *connectResponseWriter) Write(b []byte) (int, error) {
+	if rw.metadata == nil {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if rw.httpStatus != http.StatusOK {
+		// The grpc server rejected the request, the body is its error message.
+		rw.buf = append(rw.buf, b...)
+
+		return len(b), nil
+	}
+
+	rw.buf = append(rw.buf, b...)
+
+	for len(rw.buf) >= 5 {
+		n := int(binary.BigEndian.Uint32(rw.buf[1:5]))
+		if len(rw.buf) < 5+n {
+			break
+		}
+
+		data, err := rw.codec.fromGRPC(rw.buf[5 : 5+n])
+		if err != nil && rw.err == nil {
+			rw.err = err
+		}
+
+		rw.buf = rw.buf[5+n:]
+
+		if rw.streaming {
+			if _, err := rw.w.Write(appendEnvelope(nil, 0, data)); err != nil {
+				return 0, err
+			}
+		} else {
+			rw.messages = append(rw.messages, data)
+		}
+	}
+
+	return len(b), nil
+}
+
+func (rw *connectResponseWriter) Flush() {
+	if rw.metadata == nil {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if f, ok := rw.w.(http.Flusher); ok && rw.streaming {
+		f.Flush()
+	}
+}
+
+// status returns the status of the call and its trailers.
+func (rw *connectResponseWriter) status() (*status.Status, http.Header) {
+	if st := rw.requestStatus.Load(); st != nil {
+		return st, nil
+	}
+
+	if rw.httpStatus != http.StatusOK {
+		return status.New(codes.Unknown, strings.TrimSpace(string(rw.buf))), nil
+	}
+
+	if rw.err != nil {
+		return status.New(codes.Internal, rw.err.Error()), nil
+	}
+
+	trailers := make(http.Header)
+
+	for k, vv := range rw.header {
+		if strings.HasPrefix(k, "Trailer:") {
+			trailers[http.CanonicalHeaderKey(strings.TrimPrefix(k, "Trailer:"))] = vv
+		}
+	}
+
+	if bin := rw.header.Get("Grpc-Status-Details-Bin"); bin != "" {
+		if data, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(bin, "=")); err == nil {
+			var p spb.Status
+
+			if proto.Unmarshal(data, &p) == nil {
+				return status.FromProto(&p), trailers
+			}
+		}
+	}
+
+	code := codes.Unknown
+
+	if _, err := fmt.Sscan(rw.header.Get("Grpc-Status"), &code); err != nil {
+		return status.New(codes.Internal, "missing grpc status"), trailers
+	}
+
+	msg, err := url.PathUnescape(rw.header.Get("Grpc-Message"))
+	if err != nil {
+		msg = rw.header.Get("Grpc-Message")
+	}
+
+	return status.New(code, msg), trailers
+}
+
+// finish writes the unary response or the end of stream message.
+func (rw *connectResponseWriter) finish() {
+	if rw.metadata == nil {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	st, trailers := rw.status()
+
+	if rw.streaming {
+		if rw.httpStatus != http.StatusOK {
+			writeConnectError(rw.w, st, nil)
+
+			return
+		}
+
+		end := connectEndStream{Metadata: trailers}
+
+		if st.Code() != codes.OK {
+			e := newConnectError(st)
+			end.Error = &e
+		}
+
+		data, _ := json.Marshal(end) //nolint:errcheck
+
+		_, _ = rw.w.Write(appendEnvelope(nil, connectFlagEndStream, data)) //nolint:errcheck
+
+		return
+	}
+
+	header := rw.metadata
+
+	for k, vv := range trailers {
+		header["Trailer-"+k] = vv
+	}
+
+	if st.Code() != codes.OK {
+		writeConnectError(rw.w, st, header)
+
+		return
+	}
+
+	if len(rw.messages) != 1 {
+		writeConnectError(rw.w, status.New(codes.Internal, "unary call without a single response message"), header)
+
+		return
+	}
+
+	for k, vv := range header {
+		rw.w.Header()[k] = vv
+	}
+
+	rw.w.Header().Set("Content-Type", "application/"+rw.codec.name)
+	rw.w.WriteHeader(http.StatusOK)
+
+	_, _ = rw.w.Write(rw.messages[0]) //nolint:errcheck
+}
@@ -0,0 +1,233 @@
+package servers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// connectPost posts the body to the Connect method, returning the response.
+func connectPost(t *testing.T, url, contentType string, body []byte) (*http.Response, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Connect-Protocol-Version", "1")
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer res.Body.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	return res, data
+}
+
+// connectEnvelope returns the length prefixed message.
+func connectEnvelope(flags byte, data []byte) []byte {
+	b := make([]byte, 5, 5+len(data))
+	b[0] = flags
+	binary.BigEndian.PutUint32(b[1:], uint32(len(data))) //nolint:gosec
+
+	return append(b, data...)
+}
+
+func TestGRPCRest_WithConnect(t *testing.T) {
+	deny := func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if r, ok := req.(*testdata.HelloRequest); ok && r.GetName() == "denied" {
+			return nil, servers.Error(codes.PermissionDenied, "not allowed", map[string]string{"name": r.GetName()})
+		}
+
+		if r, ok := req.(*testdata.HelloRequest); ok && r.GetName() == "custom" {
+			return nil, status.Error(codes.Code(42), "custom code")
+		}
+
+		return handler(ctx, req)
+	}
+
+	grpcSrv, grpcAddr, err := startGRPCService(nil, nil, nil,
+		servers.WithStageInterceptor(servers.StageAuth, "deny", deny, nil),
+	)
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer grpcSrv.Stop()
+
+	srv, addr, err := startGRPCRestService(grpcAddr, nil, nil, servers.WithConnect(grpcSrv))
+	require.NoErrorf(t, err, "start GRPC Rest: %v", err)
+
+	defer srv.Stop()
+
+	base := fmt.Sprintf("http://%s", addr)
+
+	t.Run("unary json", func(t *testing.T) {
+		res, data := connectPost(t, base+"/helloworld.Greeter/SayHello", "application/json", []byte(`{"name":"test"}`))
+		require.Equal(t, http.StatusOK, res.StatusCode, string(data))
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		assert.JSONEq(t, `{"message":"Hello test"}`, string(data))
+	})
+
+	t.Run("unary proto", func(t *testing.T) {
+		body, err := proto.Marshal(&testdata.HelloRequest{Name: "test"})
+		require.NoError(t, err)
+
+		res, data := connectPost(t, base+"/helloworld.Greeter/SayHello", "application/proto", body)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var reply testdata.HelloReply
+
+		require.NoError(t, proto.Unmarshal(data, &reply))
+		assert.Equal(t, "Hello test", reply.GetMessage())
+	})
+
+	t.Run("unary error", func(t *testing.T) {
+		res, data := connectPost(t, base+"/helloworld.Greeter/SayHello", "application/json", []byte(`{"name":"denied"}`))
+		require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		var e struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+			Details []struct {
+				Type  string          `json:"type"`
+				Value string          `json:"value"`
+				Debug json.RawMessage `json:"debug"`
+			} `json:"details"`
+		}
+
+		require.NoError(t, json.Unmarshal(data, &e))
+		assert.Equal(t, "permission_denied", e.Code)
+		assert.Equal(t, "not allowed", e.Message)
+		require.Len(t, e.Details, 1)
+		assert.Equal(t, "google.rpc.ErrorInfo", e.Details[0].Type)
+
+		value, err := base64.RawStdEncoding.DecodeString(e.Details[0].Value)
+		require.NoError(t, err)
+
+		var info errdetails.ErrorInfo
+
+		require.NoError(t, proto.Unmarshal(value, &info))
+		assert.Equal(t, "denied", info.GetMetadata()["name"])
+		assert.NotEmpty(t, info.GetMetadata()["error_id"])
+		assert.Contains(t, string(e.Details[0].Debug), `"name":"denied"`)
+	})
+
+	t.Run("unmapped error code", func(t *testing.T) {
+		res, data := connectPost(t, base+"/helloworld.Greeter/SayHello", "application/json", []byte(`{"name":"custom"}`))
+		require.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		assert.JSONEq(t, `{"code":"unknown","message":"custom code"}`, string(data))
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		res, _ := connectPost(t, base+"/helloworld.Greeter/Unknown", "application/json", []byte(`{}`))
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("server streaming", func(t *testing.T) {
+		res, data := connectPost(t, base+"/helloworld.StreamGreeter/SayHellos", "application/connect+json",
+			connectEnvelope(0, []byte(`{"name":"a,b"}`)))
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/connect+json", res.Header.Get("Content-Type"))
+
+		var (
+			messages []string
+			end      string
+		)
+
+		for len(data) >= 5 {
+			n := binary.BigEndian.Uint32(data[1:5])
+
+			if data[0]&0x02 != 0 {
+				end = string(data[5 : 5+n])
+			} else {
+				messages = append(messages, string(data[5:5+n]))
+			}
+
+			data = data[5+n:]
+		}
+
+		assert.Equal(t, []string{`{"message":"Hello a"}`, `{"message":"Hello b"}`}, messages)
+		assert.JSONEq(t, `{}`, end)
+	})
+
+	t.Run("bidi streaming", func(t *testing.T) {
+		body := append(connectEnvelope(0, []byte(`{"name":"a"}`)), connectEnvelope(0, []byte(`{"name":"b"}`))...)
+
+		res, data := connectPost(t, base+"/helloworld.StreamGreeter/Chat", "application/connect+json", body)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var count int
+
+		for len(data) >= 5 {
+			n := binary.BigEndian.Uint32(data[1:5])
+
+			if data[0]&0x02 == 0 {
+				count++
+			}
+
+			data = data[5+n:]
+		}
+
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("gateway", func(t *testing.T) {
+		res, err := http.Get(base + "/say/test") //nolint:noctx
+		require.NoError(t, err)
+
+		defer res.Body.Close() //nolint:errcheck
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+}
+
+func TestGRPCRest_WithConnect_maxRecvMsgSize(t *testing.T) {
+	grpcSrv, grpcAddr, err := startGRPCService(nil, nil, nil, servers.WithMaxRecvMsgSize(1024))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer grpcSrv.Stop()
+
+	srv, addr, err := startGRPCRestService(grpcAddr, nil, nil, servers.WithConnect(grpcSrv))
+	require.NoErrorf(t, err, "start GRPC Rest: %v", err)
+
+	defer srv.Stop()
+
+	base := fmt.Sprintf("http://%s", addr)
+
+	t.Run("unary", func(t *testing.T) {
+		body := fmt.Sprintf(`{"name":%q}`, bytes.Repeat([]byte("a"), 2048))
+
+		res, data := connectPost(t, base+"/helloworld.Greeter/SayHello", "application/json", []byte(body))
+		require.Equal(t, http.StatusTooManyRequests, res.StatusCode, string(data))
+		assert.Contains(t, string(data), `"code":"resource_exhausted"`)
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		// The prefix declares a message far larger than the limit, without sending it.
+		prefix := []byte{0, 0x40, 0, 0, 0}
+
+		res, data := connectPost(t, base+"/helloworld.StreamGreeter/SayHellos", "application/connect+json", prefix)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.GreaterOrEqual(t, len(data), 5)
+		assert.Equal(t, byte(0x02), data[0])
+		assert.Contains(t, string(data[5:]), `"code":"resource_exhausted"`)
+	})
+}
@@ -0,0 +1,169 @@
+package servers
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCHealth controls the serving status reported by the gRPC health service of a GRPC server, see
+// WithGrpcHealthCheck.
+//
+// The overall status, service "", the server name and every registered service start as SERVING.
+//
+// The statuses set by SetServingStatus and SetAllServingStatus override the ones driven by the health checks, see
+// WithGRPCHealthSync, until ClearOverride is called, e.g. to keep a service NOT_SERVING while it drains.
+type GRPCHealth struct {
+	*health.Server
+
+	mu        sync.Mutex
+	services  []string
+	overrides map[string]bool
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+func newGRPCHealth(services []string) *GRPCHealth {
+	h := &GRPCHealth{
+		Server:    health.NewServer(),
+		overrides: make(map[string]bool),
+		stopped:   make(chan struct{}),
+	}
+
+	for _, s := range services {
+		h.services = append(h.services, s)
+		h.Server.SetServingStatus(s, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	return h
+}
+
+// SetServingStatus sets the serving status of the service, registering it when unknown. Service "" is the overall
+// status of the server. It is ignored once the server is shutting down.
+//
+// The status overrides the one driven by the health checks until ClearOverride is called.
+func (h *GRPCHealth) SetServingStatus(service string, servingStatus healthpb.HealthCheckResponse_ServingStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !slices.Contains(h.services, service) {
+		h.services = append(h.services, service)
+	}
+
+	h.overrides[service] = true
+
+	h.Server.SetServingStatus(service, servingStatus)
+}
+
+// SetAllServingStatus sets the serving status of every known service, including the overall status.
+//
+// The statuses override the ones driven by the health checks until ClearOverride is called.
+func (h *GRPCHealth) SetAllServingStatus(servingStatus healthpb.HealthCheckResponse_ServingStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, s := range h.services {
+		h.overrides[s] = true
+
+		h.Server.SetServingStatus(s, servingStatus)
+	}
+}
+
+// ClearOverride hands the serving status of the service back to the health checks, see WithGRPCHealthSync.
+// The status is kept until the next sync.
+func (h *GRPCHealth) ClearOverride(service string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.overrides, service)
+}
+
+// syncServingStatus sets the serving status driven by the health checks, unless the service status is overridden.
+func (h *GRPCHealth) syncServingStatus(service string, servingStatus healthpb.HealthCheckResponse_ServingStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.overrides[service] {
+		return
+	}
+
+	h.Server.SetServingStatus(service, servingStatus)
+}
+
+// Services returns the known service names, the overall status service "" first.
+func (h *GRPCHealth) Services() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return slices.Clone(h.services)
+}
+
+// Watch implements healthpb.HealthServer. Watch streams end with Unavailable once the server is stopping,
+// after sending NOT_SERVING, so they do not hold the graceful stop.
+func (h *GRPCHealth) Watch(in *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-h.stopped:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := h.Server.Watch(in, &healthWatchStream{Health_WatchServer: stream, ctx: ctx})
+
+	select {
+	case <-h.stopped:
+		return status.Error(codes.Unavailable, "server is shutting down")
+	default:
+		return err
+	}
+}
+
+// stop sets every service to NOT_SERVING and ends the Watch streams.
+func (h *GRPCHealth) stop() {
+	h.stopOnce.Do(func() {
+		h.Shutdown()
+
+		close(h.stopped)
+	})
+}
+
+// healthWatchStream overrides the context of a Watch stream.
+type healthWatchStream struct {
+	healthpb.Health_WatchServer
+
+	ctx context.Context //nolint:containedctx
+}
+
+func (s *healthWatchStream) Context() context.Context {
+	return s.ctx
+}
+
+// registeredServices returns the names of the services registered in the server.
+func registeredServices(s *grpc.Server) []string {
+	info := s.GetServiceInfo()
+	services := make([]string, 0, len(info))
+
+	for name := range info {
+		services = append(services, name)
+	}
+
+	slices.Sort(services)
+
+	return services
+}
+
+// Health returns the controller of the gRPC health service, nil when WithGrpcHealthCheck is not set.
+func (srv *GRPC) Health() *GRPCHealth {
+	return srv.health
+}
@@ -0,0 +1,152 @@
+package servers_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dohernandez/servers"
+	"github.com/hellofresh/health-go/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPC_Health(t *testing.T) {
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGrpcHealthCheck())
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	stopped := false
+
+	defer func() {
+		if !stopped {
+			srv.Stop()
+		}
+	}()
+
+	assert.Equal(t, []string{"", "Test service", "helloworld.Greeter", "helloworld.StreamGreeter"}, srv.Health().Services())
+
+	healthClient := grpc_health_v1.NewHealthClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	watch, err := healthClient.Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: "helloworld.Greeter"})
+	require.NoError(t, err)
+
+	res, err := watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, res.GetStatus())
+
+	srv.Health().SetServingStatus("helloworld.Greeter", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	res, err = watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, res.GetStatus())
+
+	srv.Health().SetServingStatus("helloworld.Greeter", grpc_health_v1.HealthCheckResponse_SERVING)
+
+	res, err = watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, res.GetStatus())
+
+	// Stopping the server does not wait for the watchers, which get NOT_SERVING first.
+	done := make(chan struct{})
+
+	go func() {
+		srv.Stop()
+		close(done)
+	}()
+
+	res, err = watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, res.GetStatus())
+
+	_, err = watch.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	select {
+	case <-done:
+		stopped = true
+	case <-time.After(time.Second):
+		t.Fatal("server stop is waiting for the health watchers")
+	}
+}
+
+func TestHealthCheck_GRPCHealthSync(t *testing.T) {
+	grpcSrv, grpcAddr, err := startGRPCService(nil, nil, nil, servers.WithGrpcHealthCheck())
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer grpcSrv.Stop()
+
+	var dbDown atomic.Bool
+
+	srv := servers.NewHealthCheck(
+		servers.Config{Name: "Health test service"},
+		servers.WithHealthCheck(health.Config{
+			Name:      "db",
+			Timeout:   time.Second,
+			SkipOnErr: true,
+			Check: func(context.Context) error {
+				if dbDown.Load() {
+					return errors.New("db down")
+				}
+
+				return nil
+			},
+		}),
+		servers.WithGRPC(grpcSrv),
+		servers.WithGRPCHealthSync(10*time.Millisecond, servers.GRPCServiceDependencies{
+			"helloworld.Greeter": {"db"},
+		}),
+	)
+
+	go srv.Start() //nolint:errcheck
+
+	defer srv.Stop()
+
+	healthClient := grpc_health_v1.NewHealthClient(dialGRPC(t, grpcAddr))
+
+	servingStatus := func(service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		res, err := healthClient.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+
+		return res.GetStatus()
+	}
+
+	dbDown.Store(true)
+
+	require.Eventually(t, func() bool {
+		return servingStatus("helloworld.Greeter") == grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 10*time.Millisecond)
+
+	// The db check is skipped on error for the other services.
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, servingStatus(""))
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, servingStatus("helloworld.StreamGreeter"))
+
+	dbDown.Store(false)
+
+	require.Eventually(t, func() bool {
+		return servingStatus("helloworld.Greeter") == grpc_health_v1.HealthCheckResponse_SERVING
+	}, time.Second, 10*time.Millisecond)
+
+	// The manual status, e.g. while draining, is not overwritten by the sync until the override is cleared.
+	grpcSrv.Health().SetServingStatus("helloworld.StreamGreeter", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, servingStatus("helloworld.StreamGreeter"))
+
+	grpcSrv.Health().ClearOverride("helloworld.StreamGreeter")
+
+	require.Eventually(t, func() bool {
+		return servingStatus("helloworld.StreamGreeter") == grpc_health_v1.HealthCheckResponse_SERVING
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,84 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// inProcessBufferSize is the size of the in-process connection buffer.
+const inProcessBufferSize = 1 << 20
+
+// inProcessNetwork is the network of the peer address of the in-process calls.
+const inProcessNetwork = "bufconn"
+
+// inProcess is the in-process listener of a GRPC server and the connection to it.
+type inProcess struct {
+	listener *bufconn.Listener
+	conn     *grpc.ClientConn
+	err      error
+}
+
+// ClientConn returns a connection to the server that does not go through the network, created on first call.
+// Calls go through the server, so they run its interceptor chain, and can be made before Start. The connection is
+// closed on Stop, after which grpc.ErrServerStopped is returned when it was not created.
+func (srv *GRPC) ClientConn() (*grpc.ClientConn, error) {
+	srv.inProcessOnce.Do(func() {
+		lis := bufconn.Listen(inProcessBufferSize)
+
+		conn, err := grpc.NewClient("passthrough:///in-process",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			srv.inProcess.err = fmt.Errorf("in-process connection: %w", err)
+
+			return
+		}
+
+		srv.inProcess = inProcess{listener: lis, conn: conn}
+
+		go func() {
+			_ = srv.grpcServer.Serve(lis) //nolint:errcheck
+		}()
+	})
+
+	return srv.inProcess.conn, srv.inProcess.err
+}
+
+// GRPCRestRegisterServiceConn is an interface for a grpc rest service that registers its handler on a gRPC
+// connection, e.g. with the generated RegisterXHandler.
+type GRPCRestRegisterServiceConn interface {
+	RegisterServiceHandlerConn(mux *runtime.ServeMux, conn *grpc.ClientConn) error
+}
+
+// WithInProcessGRPC registers the service handlers to the mux server on the in-process connection of the GRPC
+// server, see GRPC.ClientConn, so the gateway neither dials the GRPC server address nor waits for it to start.
+// Apply to GRPCRest server instances.
+func WithInProcessGRPC(grpcSrv *GRPC, services ...GRPCRestRegisterServiceConn) Option {
+	return func(srv any) {
+		s, ok := srv.(*GRPCRest)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		for _, r := range services {
+			s.options.register = append(s.options.register, func(mux *runtime.ServeMux) error {
+				conn, err := grpcSrv.ClientConn()
+				if err != nil {
+					return err
+				}
+
+				return r.RegisterServiceHandlerConn(mux, conn)
+			})
+		}
+	}
+}
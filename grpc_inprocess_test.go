@@ -0,0 +1,88 @@
+package servers_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/bool64/ctxd"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type gRPCRestConnTestServer struct{}
+
+// RegisterServiceHandlerConn registers the service implementation to mux on the connection.
+func (gRPCRestConnTestServer) RegisterServiceHandlerConn(mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	return testdata.RegisterGreeterHandler(context.Background(), mux, conn)
+}
+
+func TestGRPC_ClientConn(t *testing.T) {
+	var calls []string
+
+	count := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		calls = append(calls, info.FullMethod)
+
+		return handler(ctx, req)
+	}
+
+	grpcSrv := servers.NewGRPC(
+		servers.Config{Name: "Test service"},
+		servers.WithRegisterService(newGRPCTestServer(ctxd.NoOpLogger{})),
+		servers.WithStageInterceptor(servers.StageAuth, "count", count, nil),
+	)
+
+	defer grpcSrv.Stop()
+
+	// The server is not started, calls only go through the in-process connection.
+	conn, err := grpcSrv.ClientConn()
+	require.NoError(t, err)
+
+	again, err := grpcSrv.ClientConn()
+	require.NoError(t, err)
+	assert.Same(t, conn, again)
+
+	reply, err := testdata.NewGreeterClient(conn).SayHello(context.Background(), &testdata.HelloRequest{Name: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello test", reply.GetMessage())
+
+	srv, err := servers.NewGRPCRest(
+		servers.Config{Name: "Test service"},
+		servers.WithAddrAssigned(),
+		servers.WithInProcessGRPC(grpcSrv, gRPCRestConnTestServer{}),
+	)
+	require.NoError(t, err)
+
+	go func() {
+		_ = srv.Start() //nolint:errcheck
+	}()
+
+	defer srv.Stop()
+
+	res, err := http.Get(fmt.Sprintf("http://%s/say/gateway", <-srv.AddrAssigned)) //nolint:noctx
+	require.NoError(t, err)
+
+	defer res.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{"message":"Hello gateway"}`, string(body))
+	assert.Equal(t, []string{testdata.Greeter_SayHello_FullMethodName, testdata.Greeter_SayHello_FullMethodName}, calls)
+}
+
+func TestGRPC_ClientConn_stopped(t *testing.T) {
+	grpcSrv := servers.NewGRPC(servers.Config{Name: "Test service"})
+
+	grpcSrv.Stop()
+
+	_, err := grpcSrv.ClientConn()
+	require.ErrorIs(t, err, grpc.ErrServerStopped)
+}
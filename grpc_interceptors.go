@@ -0,0 +1,153 @@
+package servers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"google.golang.org/grpc"
+)
+
+// ErrUnknownInterceptorStage is returned when an interceptor is added to a stage that does not exist.
+var ErrUnknownInterceptorStage = errors.New("unknown interceptor stage")
+
+// InterceptorStage is a named stage of the GRPC interceptor chain.
+type InterceptorStage string
+
+// Interceptor stages, in the order they run.
+const (
+	StageRecovery   InterceptorStage = "recovery"
+	StageRequestID  InterceptorStage = "request-id"
+	StageTracing    InterceptorStage = "tracing"
+	StageLogging    InterceptorStage = "logging"
+	StageMetrics    InterceptorStage = "metrics"
+	StageAuth       InterceptorStage = "auth"
+	StageRateLimit  InterceptorStage = "rate-limit"
+	StageValidation InterceptorStage = "validation"
+)
+
+// interceptorStages are the stages of the GRPC interceptor chain, in the order they run.
+var interceptorStages = []InterceptorStage{
+	StageRecovery,
+	StageRequestID,
+	StageTracing,
+	StageLogging,
+	StageMetrics,
+	StageAuth,
+	StageRateLimit,
+	StageValidation,
+}
+
+// Interceptor slots within a stage.
+const (
+	slotBefore = "before"
+	slotStage  = ""
+	slotAfter  = "after"
+)
+
+// namedInterceptor is an interceptor placed in a slot of a stage of the chain.
+type namedInterceptor struct {
+	stage  InterceptorStage
+	slot   string
+	name   string
+	unary  grpc.UnaryServerInterceptor
+	stream grpc.StreamServerInterceptor
+}
+
+// String returns the interceptor as "stage:name", prefixed by the slot when it is before or after the stage.
+func (i namedInterceptor) String() string {
+	s := string(i.stage) + ":" + i.name
+
+	if i.slot != slotStage {
+		s = i.slot + " " + s
+	}
+
+	return s
+}
+
+// WithStageInterceptor adds the interceptors to the stage, after the ones set up by the server options for it,
+// e.g. WithLogger for StageLogging. Either unary or stream can be nil.
+// Apply to GRPC server instances.
+func WithStageInterceptor(stage InterceptorStage, name string, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) Option {
+	return withNamedInterceptor(stage, slotStage, name, unary, stream)
+}
+
+// WithInterceptorBefore adds the interceptors in the slot running before the stage. Either unary or stream can be
+// nil.
+// Apply to GRPC server instances.
+func WithInterceptorBefore(stage InterceptorStage, name string, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) Option {
+	return withNamedInterceptor(stage, slotBefore, name, unary, stream)
+}
+
+// WithInterceptorAfter adds the interceptors in the slot running after the stage. Either unary or stream can be
+// nil.
+// Apply to GRPC server instances.
+func WithInterceptorAfter(stage InterceptorStage, name string, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) Option {
+	return withNamedInterceptor(stage, slotAfter, name, unary, stream)
+}
+
+// withNamedInterceptor adds the interceptors to the slot of the stage. When the stage does not exist, Start fails
+// with ErrUnknownInterceptorStage.
+func withNamedInterceptor(stage InterceptorStage, slot, name string, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) Option {
+	return func(srv any) {
+		s, ok := srv.(*GRPC)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		if !slices.Contains(interceptorStages, stage) {
+			s.options.errs = append(s.options.errs, fmt.Errorf("%w: %s", ErrUnknownInterceptorStage, stage))
+
+			return
+		}
+
+		s.options.interceptors = append(s.options.interceptors, namedInterceptor{
+			stage:  stage,
+			slot:   slot,
+			name:   name,
+			unary:  unary,
+			stream: stream,
+		})
+	}
+}
+
+// interceptorChain returns the interceptors ordered by stage and slot, keeping the options order within a slot.
+// builtin are the interceptors set up by the server options, running first within their stage.
+func interceptorChain(builtin, interceptors []namedInterceptor) []namedInterceptor {
+	chain := make([]namedInterceptor, 0, len(builtin)+len(interceptors))
+
+	for _, stage := range interceptorStages {
+		for _, slot := range []string{slotBefore, slotStage, slotAfter} {
+			if slot == slotStage {
+				for _, i := range builtin {
+					if i.stage == stage {
+						chain = append(chain, i)
+					}
+				}
+			}
+
+			for _, i := range interceptors {
+				if i.stage == stage && i.slot == slot {
+					chain = append(chain, i)
+				}
+			}
+		}
+	}
+
+	return chain
+}
+
+// InterceptorChain returns the effective interceptor chain of the server, outermost first, one entry per
+// interceptor as "stage:name", prefixed by "before" or "after" when placed in the slots around the stage.
+//
+// Interceptors added through WithServerOption run after the chain and are not listed.
+func (srv *GRPC) InterceptorChain() []string {
+	chain := make([]string, 0, len(srv.chain))
+
+	for _, i := range srv.chain {
+		chain = append(chain, i.String())
+	}
+
+	return chain
+}
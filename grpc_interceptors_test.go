@@ -0,0 +1,63 @@
+package servers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestGRPC_InterceptorChain(t *testing.T) {
+	var calls []string
+
+	record := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			calls = append(calls, name)
+
+			return handler(ctx, req)
+		}
+	}
+
+	// Options are given in reverse order of the stages.
+	srv, addr, err := startGRPCService(ctxd.NoOpLogger{}, nil, nil,
+		servers.WithChainUnaryInterceptor(record("custom")),
+		servers.WithStageInterceptor(servers.StageValidation, "validator", record("validator"), nil),
+		servers.WithInterceptorBefore(servers.StageRateLimit, "tenant", record("tenant"), nil),
+		servers.WithStageInterceptor(servers.StageAuth, "jwt", record("jwt"), nil),
+		servers.WithGRPCObserver(recordingObserver{name: "observer", calls: &calls}),
+		servers.WithInterceptorAfter(servers.StageRecovery, "panics", record("panics"), nil),
+	)
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = testdata.NewGreeterClient(dialGRPC(t, addr)).SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"panics", "observer", "jwt", "tenant", "validator", "custom"}, calls)
+
+	assert.Equal(t, []string{
+		"after recovery:panics",
+		"logging:logging",
+		"metrics:servers_test.recordingObserver",
+		"auth:jwt",
+		"before rate-limit:tenant",
+		"validation:validator",
+		"after validation:chain-unary-interceptor",
+	}, srv.InterceptorChain())
+
+	err = servers.NewGRPC(servers.Config{},
+		servers.WithStageInterceptor("unknown", "name", nil, nil),
+	).Start()
+	require.ErrorIs(t, err, servers.ErrGRPCStart)
+	assert.ErrorIs(t, err, servers.ErrUnknownInterceptorStage)
+}
@@ -0,0 +1,276 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/bool64/zapctxd"
+	grpcLogging "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"google.golang.org/grpc"
+)
+
+// LogLevel is the level a gRPC call is logged with.
+type LogLevel string
+
+// Log levels.
+const (
+	LogLevelDebug LogLevel = "debug"
+	LogLevelInfo  LogLevel = "info"
+	LogLevelWarn  LogLevel = "warn"
+	LogLevelError LogLevel = "error"
+)
+
+func (l LogLevel) grpcLevel() (grpcLogging.Level, bool) {
+	switch l {
+	case LogLevelDebug:
+		return grpcLogging.LevelDebug, true
+	case LogLevelInfo:
+		return grpcLogging.LevelInfo, true
+	case LogLevelWarn:
+		return grpcLogging.LevelWarn, true
+	case LogLevelError:
+		return grpcLogging.LevelError, true
+	default:
+		return 0, false
+	}
+}
+
+// LogEvent is a gRPC call event that can be logged.
+type LogEvent string
+
+// Log events.
+const (
+	// LogEventStart logs the "started call" message.
+	LogEventStart LogEvent = "start"
+	// LogEventFinish logs the "finished call" message.
+	LogEventFinish LogEvent = "finish"
+	// LogEventPayload logs the request and response payloads, see WithPayloadLogging.
+	LogEventPayload LogEvent = "payload"
+)
+
+// GRPCLoggingRule configures the logging of the matching methods.
+type GRPCLoggingRule struct {
+	// Methods are the full method glob patterns the rule applies to, e.g. "/grpc.health.v1.Health/*".
+	Methods []string
+	// Exclude disables the logging of the matching methods.
+	Exclude bool
+	// Level is the level successful calls are logged with. Failed calls are logged at least with the level
+	// mapped from their code. Defaults to the level mapped from the code.
+	Level LogLevel
+}
+
+// GRPCLoggingConfig contains configuration options for the gRPC calls logging.
+type GRPCLoggingConfig struct {
+	// Rules are evaluated in order and the first rule matching the method applies.
+	// Methods no rule matches are logged with the defaults.
+	Rules []GRPCLoggingRule
+	// Events are the events logged. Defaults to start and finish.
+	Events []LogEvent
+	// SuccessSampleRate is the fraction, in (0, 1], of successful calls logged. Zero logs every call.
+	// Failed and slow calls are always logged.
+	SuccessSampleRate float64
+	// SlowThreshold escalates to warn the finished calls taking longer, successful or failed. Zero disables it.
+	SlowThreshold time.Duration
+}
+
+// NoisyMethods returns the full method patterns of the health check and reflection services, usually
+// excluded from logging.
+func NoisyMethods() []string {
+	return []string{
+		"/grpc.health.v1.Health/*",
+		"/grpc.reflection.v1.ServerReflection/*",
+		"/grpc.reflection.v1alpha.ServerReflection/*",
+	}
+}
+
+func (c GRPCLoggingConfig) events() []grpcLogging.LoggableEvent {
+	if len(c.Events) == 0 {
+		return []grpcLogging.LoggableEvent{grpcLogging.StartCall, grpcLogging.FinishCall}
+	}
+
+	events := make([]grpcLogging.LoggableEvent, 0, len(c.Events))
+
+	if slices.Contains(c.Events, LogEventStart) {
+		events = append(events, grpcLogging.StartCall)
+	}
+
+	if slices.Contains(c.Events, LogEventFinish) {
+		events = append(events, grpcLogging.FinishCall)
+	}
+
+	return events
+}
+
+func (c GRPCLoggingConfig) decide(fullMethod string) *logDecision {
+	d := &logDecision{
+		sampled: c.SuccessSampleRate <= 0 || c.SuccessSampleRate >= 1 || rand.Float64() < c.SuccessSampleRate, //nolint:gosec
+		start:   time.Now(),
+	}
+
+	for _, r := range c.Rules {
+		if !matchFullMethod(r.Methods, fullMethod) {
+			continue
+		}
+
+		d.excluded = r.Exclude
+		d.level, d.hasLevel = r.Level.grpcLevel()
+
+		break
+	}
+
+	return d
+}
+
+// WithLoggingConfig sets the configuration of the gRPC calls logging enabled by WithLogger.
+// Apply to GRPC server instances.
+func WithLoggingConfig(config GRPCLoggingConfig) Option {
+	return func(srv any) {
+		s, ok := srv.(*GRPC)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		s.options.logging = config
+	}
+}
+
+type logDecisionCtxKey struct{}
+
+// logDecision is the per call logging decision, made before the call is handled.
+type logDecision struct {
+	excluded bool
+	sampled  bool
+	hasLevel bool
+	level    grpcLogging.Level
+	start    time.Time
+}
+
+func logDecisionFromContext(ctx context.Context) (*logDecision, bool) {
+	d, ok := ctx.Value(logDecisionCtxKey{}).(*logDecision)
+
+	return d, ok
+}
+
+// loggingExcluded reports whether the call of the context is excluded from logging.
+func loggingExcluded(ctx context.Context) bool {
+	d, ok := logDecisionFromContext(ctx)
+
+	return ok && d.excluded
+}
+
+// loggingInterceptors returns the unary and stream interceptors logging the calls according to the config.
+func loggingInterceptors(logger ctxd.Logger, config GRPCLoggingConfig) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	l := grpcInterceptorLogger(logger, config)
+	opts := []grpcLogging.Option{grpcLogging.WithLogOnEvents(config.events()...)}
+
+	unary := grpcLogging.UnaryServerInterceptor(l, opts...)
+	stream := grpcLogging.StreamServerInterceptor(l, opts...)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			d := config.decide(info.FullMethod)
+
+			ctx = context.WithValue(ctx, logDecisionCtxKey{}, d)
+
+			if d.excluded {
+				return handler(ctx, req)
+			}
+
+			return unary(ctx, req, info, handler)
+		}, func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			d := config.decide(info.FullMethod)
+
+			ss = &contextServerStream{
+				ServerStream: ss,
+				ctx:          context.WithValue(ss.Context(), logDecisionCtxKey{}, d),
+			}
+
+			if d.excluded {
+				return handler(srv, ss)
+			}
+
+			return stream(srv, ss, info, handler)
+		}
+}
+
+// contextServerStream overrides the context of the wrapped grpc.ServerStream.
+type contextServerStream struct {
+	grpc.ServerStream
+
+	ctx context.Context //nolint:containedctx
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcInterceptorLogger adapts zapctxd logger to interceptor logger, applying the per call logging decision.
+func grpcInterceptorLogger(l ctxd.Logger, config GRPCLoggingConfig) grpcLogging.Logger {
+	if sl, ok := l.(interface{ SkipCaller() *zapctxd.Logger }); ok {
+		l = sl.SkipCaller()
+	}
+
+	return grpcLogging.LoggerFunc(func(ctx context.Context, lvl grpcLogging.Level, msg string, fields ...any) {
+		if d, ok := logDecisionFromContext(ctx); ok {
+			var logged bool
+
+			lvl, logged = d.resolveLevel(lvl, msg, fields, config.SlowThreshold)
+			if !logged {
+				return
+			}
+		}
+
+		ctx = ctxd.AddFields(ctx, fields...)
+
+		switch lvl {
+		case grpcLogging.LevelDebug:
+			l.Debug(ctx, msg)
+		case grpcLogging.LevelInfo:
+			l.Info(ctx, msg)
+		case grpcLogging.LevelWarn:
+			l.Warn(ctx, msg)
+		case grpcLogging.LevelError:
+			l.Error(ctx, msg)
+		default:
+			panic(fmt.Sprintf("unknown level %v", lvl))
+		}
+	})
+}
+
+// resolveLevel returns the level the message is logged with, reporting false when it must not be logged.
+func (d *logDecision) resolveLevel(lvl grpcLogging.Level, msg string, fields []any, slowThreshold time.Duration) (grpcLogging.Level, bool) {
+	failed := false
+
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "grpc.code" {
+			failed = fields[i+1] != "OK"
+
+			break
+		}
+	}
+
+	slow := msg == "finished call" && slowThreshold > 0 && time.Since(d.start) > slowThreshold
+
+	switch {
+	case failed:
+		// Failed calls are always logged, at least with the level mapped from the code.
+		if d.hasLevel && d.level > lvl {
+			lvl = d.level
+		}
+	case !d.sampled && !slow:
+		return lvl, false
+	case d.hasLevel:
+		lvl = d.level
+	}
+
+	// Slow calls are escalated whatever their code and sampling.
+	if slow && lvl < grpcLogging.LevelWarn {
+		lvl = grpcLogging.LevelWarn
+	}
+
+	return lvl, true
+}
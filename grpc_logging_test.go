@@ -0,0 +1,211 @@
+package servers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/bool64/zapctxd"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPC_StartServing_WithLoggingConfig(t *testing.T) {
+	var buff syncBuffer
+
+	logger := zapctxd.New(zapctxd.Config{
+		FieldNames: ctxd.FieldNames{
+			Timestamp: "timestamp",
+			Message:   "message",
+		},
+		Level:  zap.DebugLevel,
+		Output: &buff,
+	})
+
+	srv, addr, err := startGRPCService(
+		logger,
+		nil,
+		nil,
+		servers.WithGrpcHealthCheck(),
+		servers.WithLoggingConfig(servers.GRPCLoggingConfig{
+			Rules: []servers.GRPCLoggingRule{
+				{
+					Methods: servers.NoisyMethods(),
+					Exclude: true,
+				},
+				{
+					Methods: []string{"/helloworld.Greeter/*"},
+					Level:   servers.LogLevelDebug,
+				},
+			},
+			Events:        []servers.LogEvent{servers.LogEventFinish},
+			SlowThreshold: time.Hour,
+		}),
+	)
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "grpc connection: %v", err)
+
+	defer conn.Close() //nolint:errcheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err, "health call: %v", err)
+
+	_, err = testdata.NewGreeterClient(conn).SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	require.NoErrorf(t, err, "SayHello request: %v", err)
+
+	lines := logLines(t, &buff)
+
+	assert.Nil(t, findLogLine(lines, "started call"), "start event logged")
+
+	var finished []map[string]any
+
+	for _, l := range lines {
+		if l["message"] == "finished call" {
+			finished = append(finished, l)
+		}
+	}
+
+	require.Len(t, finished, 1, "health check call logged")
+	assert.Equal(t, "SayHello", finished[0]["grpc.method"])
+	assert.Equal(t, "debug", finished[0]["level"])
+}
+
+func TestGRPC_StartServing_WithLoggingConfig_SlowThreshold(t *testing.T) {
+	var buff syncBuffer
+
+	logger := zapctxd.New(zapctxd.Config{
+		FieldNames: ctxd.FieldNames{
+			Timestamp: "timestamp",
+			Message:   "message",
+		},
+		Level:  zap.DebugLevel,
+		Output: &buff,
+	})
+
+	srv, addr, err := startGRPCService(
+		logger,
+		nil,
+		nil,
+		servers.WithLoggingConfig(servers.GRPCLoggingConfig{
+			SlowThreshold: time.Nanosecond,
+		}),
+		servers.WithStageInterceptor(servers.StageAuth, "not-found",
+			func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if r, ok := req.(*testdata.HelloRequest); ok && r.GetName() == "missing" {
+					return nil, status.Error(codes.NotFound, "not found")
+				}
+
+				return handler(ctx, req)
+			}, nil),
+	)
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "grpc connection: %v", err)
+
+	defer conn.Close() //nolint:errcheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = testdata.NewGreeterClient(conn).SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	require.NoErrorf(t, err, "SayHello request: %v", err)
+
+	finished := findLogLine(logLines(t, &buff), "finished call")
+	require.NotNil(t, finished, "finish event not logged")
+	assert.Equal(t, "warn", finished["level"])
+
+	// Failed calls are escalated too, NotFound is logged at info otherwise.
+	_, err = testdata.NewGreeterClient(conn).SayHello(ctx, &testdata.HelloRequest{Name: "missing"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	var failed map[string]any
+
+	for _, l := range logLines(t, &buff) {
+		if l["message"] == "finished call" && l["grpc.code"] == "NotFound" {
+			failed = l
+		}
+	}
+
+	require.NotNil(t, failed, "failed call not logged")
+	assert.Equal(t, "warn", failed["level"])
+}
+
+func TestGRPC_StartServing_WithLoggingConfig_SlowThresholdSampled(t *testing.T) {
+	var buff syncBuffer
+
+	logger := zapctxd.New(zapctxd.Config{
+		FieldNames: ctxd.FieldNames{
+			Timestamp: "timestamp",
+			Message:   "message",
+		},
+		Level:  zap.DebugLevel,
+		Output: &buff,
+	})
+
+	srv, addr, err := startGRPCService(
+		logger,
+		nil,
+		nil,
+		servers.WithLoggingConfig(servers.GRPCLoggingConfig{
+			Events:            []servers.LogEvent{servers.LogEventFinish},
+			SuccessSampleRate: 1e-9,
+			SlowThreshold:     20 * time.Millisecond,
+		}),
+		servers.WithStageInterceptor(servers.StageAuth, "slow",
+			func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if r, ok := req.(*testdata.HelloRequest); ok && r.GetName() == "slow" {
+					time.Sleep(50 * time.Millisecond)
+				}
+
+				return handler(ctx, req)
+			}, nil),
+	)
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "grpc connection: %v", err)
+
+	defer conn.Close() //nolint:errcheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The fast call is sampled out, the slow one is logged regardless.
+	_, err = testdata.NewGreeterClient(conn).SayHello(ctx, &testdata.HelloRequest{Name: "fast"})
+	require.NoErrorf(t, err, "SayHello request: %v", err)
+
+	_, err = testdata.NewGreeterClient(conn).SayHello(ctx, &testdata.HelloRequest{Name: "slow"})
+	require.NoErrorf(t, err, "SayHello request: %v", err)
+
+	var finished []map[string]any
+
+	for _, l := range logLines(t, &buff) {
+		if l["message"] == "finished call" {
+			finished = append(finished, l)
+		}
+	}
+
+	require.Len(t, finished, 1, "slow call not logged")
+	assert.Equal(t, "warn", finished[0]["level"])
+}
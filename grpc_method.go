@@ -0,0 +1,109 @@
+package servers
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// matchFullMethod reports whether the gRPC full method (e.g. "/helloworld.Greeter/SayHello") matches
+// any of the given glob patterns.
+//
+// Patterns follow path.Match syntax, where "*" matches all the methods of a service when used as
+// "/package.Service/*". The pattern "*" alone matches any method.
+func matchFullMethod(patterns []string, fullMethod string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == fullMethod {
+			return true
+		}
+
+		if ok, err := path.Match(p, fullMethod); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// methodDescriptor returns the descriptor of the gRPC full method, looked up in protoregistry.GlobalFiles.
+func methodDescriptor(fullMethod string) (protoreflect.MethodDescriptor, bool) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return nil, false
+	}
+
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, false
+	}
+
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, false
+	}
+
+	md := sd.Methods().ByName(protoreflect.Name(method))
+
+	return md, md != nil
+}
+
+// messageType returns the registered type of the message, or a dynamic one.
+func messageType(md protoreflect.MessageDescriptor) protoreflect.MessageType {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName()); err == nil {
+		return mt
+	}
+
+	return dynamicpb.NewMessageType(md)
+}
+
+type principalCtxKey struct{}
+
+// principalRecorderCtxKey is the context key of the principalRecorder of the call.
+type principalRecorderCtxKey struct{}
+
+// principalRecorder keeps the principal set by the interceptors running after the one that installed it, e.g.
+// for the Auditor to know the principal of the calls once they return.
+type principalRecorder struct {
+	mu        sync.Mutex
+	principal string
+}
+
+// contextWithPrincipalRecorder returns a copy of ctx recording the principal set on the contexts derived from it.
+func contextWithPrincipalRecorder(ctx context.Context) (context.Context, *principalRecorder) {
+	r := &principalRecorder{}
+
+	return context.WithValue(ctx, principalRecorderCtxKey{}, r), r
+}
+
+// get returns the recorded principal, if any.
+func (r *principalRecorder) get() (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.principal, r.principal != ""
+}
+
+// ContextWithPrincipal returns a copy of ctx carrying the authenticated principal.
+// Meant to be used by authentication interceptors so the principal is available to audit, logging and
+// rate limiting.
+func ContextWithPrincipal(ctx context.Context, principal string) context.Context {
+	if r, ok := ctx.Value(principalRecorderCtxKey{}).(*principalRecorder); ok {
+		r.mu.Lock()
+		r.principal = principal
+		r.mu.Unlock()
+	}
+
+	return context.WithValue(ctx, principalCtxKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated principal carried by ctx, if any.
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalCtxKey{}).(string)
+
+	return principal, ok && principal != ""
+}
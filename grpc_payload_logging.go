@@ -0,0 +1,342 @@
+package servers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultHTTPPayloadMaxSize is the size in bytes the HTTP bodies are buffered up to when MaxSize is not set.
+const defaultHTTPPayloadMaxSize = 64 << 10
+
+// PayloadLoggingRule configures the payload logging of the matching methods.
+type PayloadLoggingRule struct {
+	// Methods are the full method glob patterns the rule applies to, e.g. "/helloworld.Greeter/*".
+	// For the gateway, the patterns are matched against the request URL path, e.g. "/say/*".
+	Methods []string
+	// Exclude disables the payload logging of the matching methods.
+	Exclude bool
+	// SampleRate is the fraction, in (0, 1], of calls whose payloads are logged. Zero logs every call.
+	SampleRate float64
+	// MaxSize is the maximum size in bytes of a logged payload, the rest is truncated. Zero means no limit,
+	// except for the HTTP bodies, which are buffered up to 64KiB. The HTTP bodies larger than the buffer are logged
+	// without content, as they can not be redacted.
+	MaxSize int
+	// RedactFields are the field paths masked in the logged payloads, e.g. "user.password".
+	// Fields marked with the debug_redact proto option are always masked. In the HTTP bodies, which do not tell
+	// their message, every field named as a debug_redact field of a registered message is masked.
+	RedactFields []string
+}
+
+// PayloadLoggingConfig contains configuration options for the payload logging.
+type PayloadLoggingConfig struct {
+	// Rules are evaluated in order and the first rule matching the method applies.
+	// When no rule is configured every method is logged, otherwise methods no rule matches are not logged.
+	Rules []PayloadLoggingRule
+}
+
+func (c PayloadLoggingConfig) rule(method string) (PayloadLoggingRule, bool) {
+	if len(c.Rules) == 0 {
+		return PayloadLoggingRule{}, true
+	}
+
+	for _, r := range c.Rules {
+		if matchFullMethod(r.Methods, method) {
+			return r, !r.Exclude
+		}
+	}
+
+	return PayloadLoggingRule{}, false
+}
+
+func (r PayloadLoggingRule) sampled() bool {
+	return r.SampleRate <= 0 || r.SampleRate >= 1 || rand.Float64() < r.SampleRate //nolint:gosec
+}
+
+// httpMaxSize returns the maximum size in bytes of the buffered HTTP bodies.
+func (r PayloadLoggingRule) httpMaxSize() int {
+	if r.MaxSize <= 0 {
+		return defaultHTTPPayloadMaxSize
+	}
+
+	return r.MaxSize
+}
+
+// truncate cuts the payload to the rule MaxSize, reporting whether it was truncated.
+func (r PayloadLoggingRule) truncate(payload []byte) ([]byte, bool) {
+	if r.MaxSize <= 0 || len(payload) <= r.MaxSize {
+		return payload, false
+	}
+
+	return payload[:r.MaxSize], true
+}
+
+// payloadLogger logs the gRPC and HTTP payloads according to a PayloadLoggingConfig.
+type payloadLogger struct {
+	logger ctxd.Logger
+	config PayloadLoggingConfig
+}
+
+func (l payloadLogger) logMessage(ctx context.Context, rule PayloadLoggingRule, msg, field string, payload any) {
+	m, ok := payload.(proto.Message)
+	if !ok {
+		return
+	}
+
+	l.log(ctx, rule, msg, field, marshalPayload(redactMessage(m, rule.RedactFields)))
+}
+
+func (l payloadLogger) log(ctx context.Context, rule PayloadLoggingRule, msg, field string, payload []byte) {
+	content, truncated := rule.truncate(payload)
+
+	kvs := []any{field, string(content)}
+
+	if truncated {
+		kvs = append(kvs, field+"_truncated", true, field+"_size", len(payload))
+	}
+
+	l.logger.Debug(ctx, msg, kvs...)
+}
+
+func (l payloadLogger) unaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		rule, ok := l.config.rule(info.FullMethod)
+		if !ok || loggingExcluded(ctx) || !rule.sampled() {
+			return handler(ctx, req)
+		}
+
+		ctx = ctxd.AddFields(ctx, "grpc.method", info.FullMethod)
+
+		l.logMessage(ctx, rule, "request received", "grpc.request.content", req)
+
+		resp, err := handler(ctx, req)
+		if err == nil {
+			l.logMessage(ctx, rule, "response sent", "grpc.response.content", resp)
+		}
+
+		return resp, err
+	}
+}
+
+func (l payloadLogger) streamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		rule, ok := l.config.rule(info.FullMethod)
+		if !ok || loggingExcluded(ss.Context()) || !rule.sampled() {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &payloadLoggingServerStream{
+			ServerStream: ss,
+			ctx:          ctxd.AddFields(ss.Context(), "grpc.method", info.FullMethod),
+			logger:       l,
+			rule:         rule,
+		})
+	}
+}
+
+type payloadLoggingServerStream struct {
+	grpc.ServerStream
+
+	ctx    context.Context //nolint:containedctx
+	logger payloadLogger
+	rule   PayloadLoggingRule
+}
+
+func (s *payloadLoggingServerStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.logger.logMessage(s.ctx, s.rule, "response sent", "grpc.response.content", m)
+	}
+
+	return err
+}
+
+func (s *payloadLoggingServerStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.logger.logMessage(s.ctx, s.rule, "request received", "grpc.request.content", m)
+	}
+
+	return err
+}
+
+// WithPayloadLogging sets service to log the request and response payloads at debug level using the logger
+// set by WithLogger.
+// Apply to GRPC server instances.
+func WithPayloadLogging(config PayloadLoggingConfig) Option {
+	return func(srv any) {
+		s, ok := srv.(*GRPC)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		s.options.payloadLogging = &config
+	}
+}
+
+// WithGatewayPayloadLogging sets service to log the HTTP request and response bodies at debug level.
+// Rule methods are matched against the request URL path and RedactFields against the JSON field names.
+// The fields marked with the debug_redact option are masked by name, regardless of the message of the body.
+// Bodies that are not JSON, such as Server-Sent Events, are logged without content.
+// Apply to GRPCRest server instances.
+func WithGatewayPayloadLogging(logger ctxd.Logger, config PayloadLoggingConfig) Option {
+	return func(srv any) {
+		s, ok := srv.(*GRPCRest)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		l := payloadLogger{
+			logger: logger,
+			config: config,
+		}
+
+		s.options.middlewares = append(s.options.middlewares, l.httpMiddleware)
+	}
+}
+
+func (l payloadLogger) httpMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := l.config.rule(r.URL.Path)
+		if !ok || !rule.sampled() {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		ctx := ctxd.AddFields(r.Context(), "http.method", r.Method, "http.path", r.URL.Path)
+		limit := rule.httpMaxSize()
+
+		if r.Body != nil && r.Body != http.NoBody {
+			// Only the logged prefix is buffered, the rest is read through by the handler.
+			body, err := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+
+			r.Body = readCloser{
+				Reader: io.MultiReader(bytes.NewReader(body), r.Body),
+				Closer: r.Body,
+			}
+
+			if err == nil && len(body) > 0 {
+				size := len(body)
+				if size > limit {
+					size = max(size, int(r.ContentLength))
+				}
+
+				l.logBody(ctx, rule, "http request body", "http.request.body", body[:min(len(body), limit)], size)
+			}
+		}
+
+		rw := &payloadLoggingResponseWriter{ResponseWriter: w, limit: limit}
+
+		start := time.Now()
+
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		if rw.hijacked {
+			return
+		}
+
+		l.logBody(
+			ctxd.AddFields(ctx, "http.status", rw.statusCode(), "http.time_ms", time.Since(start).Milliseconds()),
+			rule,
+			"http response body",
+			"http.response.body",
+			rw.body.Bytes(),
+			rw.size,
+		)
+	})
+}
+
+// logBody logs the HTTP body of size bytes, of which body is the buffered prefix. The bodies larger than the
+// buffer and the bodies that are not JSON are logged without content, as they can not be redacted.
+func (l payloadLogger) logBody(ctx context.Context, rule PayloadLoggingRule, msg, field string, body []byte, size int) {
+	if size > len(body) {
+		l.logger.Debug(ctx, msg, field+"_truncated", true, field+"_size", size)
+
+		return
+	}
+
+	redacted, ok := redactJSON(body, rule.RedactFields)
+	if !ok {
+		l.logger.Debug(ctx, msg, field+"_omitted", true, field+"_size", size)
+
+		return
+	}
+
+	l.log(ctx, rule, msg, field, redacted)
+}
+
+// readCloser combines a reader with the closer of the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// payloadLoggingResponseWriter keeps a copy of the response body, up to limit bytes.
+type payloadLoggingResponseWriter struct {
+	http.ResponseWriter
+
+	status   int
+	limit    int
+	size     int
+	body     bytes.Buffer
+	hijacked bool
+}
+
+func (w *payloadLoggingResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *payloadLoggingResponseWriter) Write(b []byte) (int, error) {
+	if n := w.limit - w.body.Len(); n > 0 {
+		w.body.Write(b[:min(n, len(b))])
+	}
+
+	w.size += len(b)
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *payloadLoggingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, for the protocol upgrades. The hijacked connections are not logged.
+func (w *payloadLoggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+
+	return conn, brw, err
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (w *payloadLoggingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *payloadLoggingResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
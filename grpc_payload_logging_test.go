@@ -0,0 +1,310 @@
+package servers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/bool64/zapctxd"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/serverstest"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// syncBuffer is a bytes.Buffer safe to be written by the server while read by the test.
+type syncBuffer struct {
+	mu   sync.Mutex
+	buff bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buff.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return bytes.Clone(b.buff.Bytes())
+}
+
+func logLines(t *testing.T, buff interface{ Bytes() []byte }) []map[string]any {
+	t.Helper()
+
+	var lines []map[string]any
+
+	for _, line := range bytes.Split(buff.Bytes(), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		out := make(map[string]any)
+		require.NoError(t, json.Unmarshal(line, &out), "unmarshal log")
+
+		lines = append(lines, out)
+	}
+
+	return lines
+}
+
+func findLogLine(lines []map[string]any, msg string) map[string]any {
+	for _, l := range lines {
+		if l["message"] == msg {
+			return l
+		}
+	}
+
+	return nil
+}
+
+func TestGRPC_StartServing_WithPayloadLogging(t *testing.T) {
+	var buff bytes.Buffer
+
+	logger := zapctxd.New(zapctxd.Config{
+		FieldNames: ctxd.FieldNames{
+			Timestamp: "timestamp",
+			Message:   "message",
+		},
+		Level:  zap.DebugLevel,
+		Output: &buff,
+	})
+
+	srv, addr, err := startGRPCService(
+		logger,
+		nil,
+		nil,
+		servers.WithPayloadLogging(servers.PayloadLoggingConfig{
+			Rules: []servers.PayloadLoggingRule{
+				{
+					Methods:      []string{"/helloworld.Greeter/*"},
+					RedactFields: []string{"name"},
+					MaxSize:      23,
+				},
+			},
+		}),
+	)
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "grpc connection: %v", err)
+
+	defer conn.Close() //nolint:errcheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = testdata.NewGreeterClient(conn).SayHello(ctx, &testdata.HelloRequest{Name: "secret name"})
+	require.NoErrorf(t, err, "SayHello request: %v", err)
+
+	lines := logLines(t, &buff)
+
+	req := findLogLine(lines, "request received")
+	require.NotNil(t, req, "request payload not logged")
+	assert.JSONEq(t, `{"name":"[REDACTED]"}`, req["grpc.request.content"].(string))
+
+	resp := findLogLine(lines, "response sent")
+	require.NotNil(t, resp, "response payload not logged")
+	assert.Contains(t, resp["grpc.response.content"], `"message"`)
+	assert.Equal(t, true, resp["grpc.response.content_truncated"])
+}
+
+func TestGRPCRest_StartServing_WithGatewayPayloadLogging(t *testing.T) {
+	var buff syncBuffer
+
+	logger := zapctxd.New(zapctxd.Config{
+		FieldNames: ctxd.FieldNames{
+			Timestamp: "timestamp",
+			Message:   "message",
+		},
+		Level:  zap.DebugLevel,
+		Output: &buff,
+	})
+
+	grpcSrv, grpcAddr, err := startGRPCService(nil, nil, nil)
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer grpcSrv.Stop()
+
+	srv, addr, err := startGRPCRestService(grpcAddr, nil, nil, servers.WithGatewayPayloadLogging(logger, servers.PayloadLoggingConfig{
+		Rules: []servers.PayloadLoggingRule{
+			{
+				Methods:      []string{"/say/*"},
+				RedactFields: []string{"message"},
+			},
+		},
+	}))
+	require.NoErrorf(t, err, "start GRPC Rest: %v", err)
+
+	defer srv.Stop()
+
+	res, err := http.Get(fmt.Sprintf("http://%s/say/test", addr))
+	require.NoError(t, err)
+
+	_ = res.Body.Close() //nolint:errcheck
+
+	var resp map[string]any
+
+	// The response body is logged once the handler returns, which can happen after the client read it.
+	require.Eventually(t, func() bool {
+		resp = findLogLine(logLines(t, &buff), "http response body")
+
+		return resp != nil
+	}, time.Second, 10*time.Millisecond, "response body not logged")
+	assert.Equal(t, `{"message":"[REDACTED]"}`, resp["http.response.body"])
+	assert.Equal(t, "/say/test", resp["http.path"])
+}
+
+// The credentials message registers a debug_redact field, which is masked by name in the gateway bodies.
+func init() {
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("servers_test/credentials.proto"),
+		Package: proto.String("servers_test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Credentials"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("api_key"),
+				JsonName: proto.String("apiKey"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Options:  &descriptorpb.FieldOptions{DebugRedact: proto.Bool(true)},
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(err)
+	}
+}
+
+func TestGRPCRest_StartServing_WithGatewayPayloadLogging_bodies(t *testing.T) {
+	var buff syncBuffer
+
+	s := serverstest.Start(t,
+		serverstest.WithGRPC(servers.WithRegisterService(newGRPCTestServer(ctxd.NoOpLogger{}))),
+		serverstest.WithGateway(gRPCRestStreamTestServer{}, gRPCRestConnTestServer{}),
+		serverstest.WithGRPCRest(
+			servers.WithGatewayPayloadLogging(newTestLogger(&buff), servers.PayloadLoggingConfig{
+				Rules: []servers.PayloadLoggingRule{
+					{Methods: []string{"/say/*"}, MaxSize: 16},
+					{Methods: []string{"/hellos/*", "/credentials", "/secrets", "/chat"}, RedactFields: []string{"result.message"}},
+				},
+			}),
+			servers.WithWebSocket(servers.WebSocketConfig{}),
+		),
+	)
+
+	bodies := func(t *testing.T, path string, n int) []map[string]any {
+		t.Helper()
+
+		var lines []map[string]any
+
+		// The response body is logged once the handler returns, which can happen after the client read it.
+		require.Eventually(t, func() bool {
+			lines = nil
+
+			for _, l := range logLines(t, &buff) {
+				if l["http.path"] == path {
+					lines = append(lines, l)
+				}
+			}
+
+			return len(lines) == n
+		}, time.Second, 10*time.Millisecond, "bodies not logged")
+
+		return lines
+	}
+
+	t.Run("debug_redact", func(t *testing.T) {
+		res, err := http.Post(s.GRPCRestURL+"/credentials", "application/json",
+			strings.NewReader(`{"apiKey":"secret","nested":[{"api_key":"secret"}]}`))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		lines := bodies(t, "/credentials", 2)
+
+		assert.JSONEq(t,
+			`{"apiKey":"[REDACTED]","nested":[{"api_key":"[REDACTED]"}]}`,
+			lines[0]["http.request.body"].(string), //nolint:forcetypeassert
+		)
+	})
+
+	t.Run("not json", func(t *testing.T) {
+		payload := `{"apiKey":"secret"} trailing`
+
+		res, err := http.Post(s.GRPCRestURL+"/secrets", "text/plain", strings.NewReader(payload))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		l := bodies(t, "/secrets", 2)[0]
+
+		assert.NotContains(t, l, "http.request.body")
+		assert.Equal(t, true, l["http.request.body_omitted"])
+		assert.InDelta(t, len(payload), l["http.request.body_size"], 0)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		res := serverstest.Get(t, s.GRPCRestURL+"/hellos/a,b")
+
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "Hello a")
+
+		l := bodies(t, "/hellos/a,b", 1)[0]
+
+		assert.Equal(t,
+			"{\"result\":{\"message\":\"[REDACTED]\"}}\n{\"result\":{\"message\":\"[REDACTED]\"}}\n",
+			l["http.response.body"],
+		)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		payload := `{"name":"` + strings.Repeat("a", 64) + `"}`
+
+		res, err := http.Post(s.GRPCRestURL+"/say/test", "application/json", strings.NewReader(payload))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		lines := bodies(t, "/say/test", 2)
+
+		assert.NotContains(t, lines[0], "http.request.body")
+		assert.Equal(t, true, lines[0]["http.request.body_truncated"])
+		assert.InDelta(t, len(payload), lines[0]["http.request.body_size"], 0)
+
+		assert.NotContains(t, lines[1], "http.response.body")
+		assert.Equal(t, true, lines[1]["http.response.body_truncated"])
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		received, closeErr := chatWebSocket(t, s.GRPCRestURL+"/chat", nil, `{"name":"a"}`)
+
+		assert.Equal(t, []string{`{"message":"Hello a"}`}, received)
+		assert.Equal(t, 1000, closeErr.Code)
+	})
+}
@@ -0,0 +1,317 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// ErrInvalidQuotaRule is returned when a QuotaRule is not valid.
+var ErrInvalidQuotaRule = errors.New("invalid quota rule")
+
+// QuotaPeriod is the calendar window a quota is counted over.
+type QuotaPeriod string
+
+// Quota periods.
+const (
+	QuotaDaily   QuotaPeriod = "daily"
+	QuotaMonthly QuotaPeriod = "monthly"
+)
+
+// QuotaRule sets the number of requests a client is allowed to make to a method group within a calendar window.
+type QuotaRule struct {
+	// Name identifies the method group, rules must have a unique name.
+	Name string `json:"name"`
+	// Methods are the full method glob patterns of the group, e.g. "/reports.Exporter/*".
+	// Empty matches any method.
+	Methods []string `json:"methods,omitempty"`
+	// Tiers are the client tiers the rule applies to, e.g. "free". Empty matches any tier.
+	Tiers []string `json:"tiers,omitempty"`
+	// Period is the calendar window the requests are counted over.
+	Period QuotaPeriod `json:"period"`
+	// Limit is the number of requests allowed per client within the window.
+	Limit int64 `json:"limit"`
+}
+
+func (r QuotaRule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("%w: missing name", ErrInvalidQuotaRule)
+	}
+
+	if r.Period != QuotaDaily && r.Period != QuotaMonthly {
+		return fmt.Errorf("%w: %s: unknown period %q", ErrInvalidQuotaRule, r.Name, r.Period)
+	}
+
+	if r.Limit < 0 {
+		return fmt.Errorf("%w: %s: negative limit", ErrInvalidQuotaRule, r.Name)
+	}
+
+	for _, p := range r.Methods {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("%w: %s: method %q: %v", ErrInvalidQuotaRule, r.Name, p, err) //nolint:errorlint
+		}
+	}
+
+	return nil
+}
+
+func (r QuotaRule) matches(fullMethod, tier string) bool {
+	if len(r.Methods) > 0 && !matchFullMethod(r.Methods, fullMethod) {
+		return false
+	}
+
+	return len(r.Tiers) == 0 || slices.Contains(r.Tiers, tier)
+}
+
+// window returns the calendar window containing t and when it ends.
+func (r QuotaRule) window(t time.Time) (string, time.Time) {
+	if r.Period == QuotaMonthly {
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+
+		return start.Format("2006-01"), start.AddDate(0, 1, 0)
+	}
+
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	return start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+}
+
+// QuotaKey identifies the usage counter of a client for a rule within a window.
+type QuotaKey struct {
+	Client string
+	Rule   string
+	Window string
+}
+
+// String returns the key as "rule:window:client".
+func (k QuotaKey) String() string {
+	return k.Rule + ":" + k.Window + ":" + k.Client
+}
+
+// QuotaStore persists the usage counters of the QuotaLimiter.
+type QuotaStore interface {
+	// Increment adds delta to the counter of the key, returning the new value. The counter is not needed
+	// after expires.
+	Increment(ctx context.Context, key QuotaKey, delta int64, expires time.Time) (int64, error)
+	// Get returns the counter of the key, zero when missing.
+	Get(ctx context.Context, key QuotaKey) (int64, error)
+	// Delete removes the counter of the key.
+	Delete(ctx context.Context, key QuotaKey) error
+}
+
+// QuotaConfig contains configuration options for a QuotaLimiter.
+type QuotaConfig struct {
+	// Rules are the quotas, every rule matching the call is accounted.
+	Rules []QuotaRule
+	// KeyExtractor identifies the client of the call. Defaults to the "client-id" metadata key.
+	KeyExtractor ClientKeyExtractor
+	// TierExtractor identifies the tier of the client. Defaults to the "client-tier" metadata key.
+	TierExtractor ClientKeyExtractor
+	// Location is the time zone of the calendar windows. Defaults to UTC.
+	Location *time.Location
+}
+
+// QuotaUsage is the usage of a client for a rule within the current window.
+type QuotaUsage struct {
+	Rule   string      `json:"rule"`
+	Period QuotaPeriod `json:"period"`
+	Window string      `json:"window"`
+	Used   int64       `json:"used"`
+	Limit  int64       `json:"limit"`
+	Resets time.Time   `json:"resets"`
+}
+
+// QuotaLimiter is a gRPC interceptor that limits the number of requests per client over daily and monthly
+// calendar windows. Usage is counted per client and rule, in a QuotaStore.
+//
+// Calls exceeding any of the matching rules fail with ResourceExhausted, carrying QuotaFailure and RetryInfo
+// details, and are not counted. Streams count as one request on creation.
+type QuotaLimiter struct {
+	store  QuotaStore
+	config QuotaConfig
+	now    func() time.Time
+}
+
+// NewQuotaLimiter creates a new QuotaLimiter backed by the store.
+func NewQuotaLimiter(store QuotaStore, config QuotaConfig) (*QuotaLimiter, error) {
+	names := make(map[string]bool, len(config.Rules))
+
+	for _, r := range config.Rules {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+
+		if names[r.Name] {
+			return nil, fmt.Errorf("%w: %s: duplicated name", ErrInvalidQuotaRule, r.Name)
+		}
+
+		names[r.Name] = true
+	}
+
+	if config.KeyExtractor == nil {
+		config.KeyExtractor = MetadataKeyExtractor("client-id")
+	}
+
+	if config.TierExtractor == nil {
+		config.TierExtractor = MetadataKeyExtractor("client-tier")
+	}
+
+	if config.Location == nil {
+		config.Location = time.UTC
+	}
+
+	config.Rules = slices.Clone(config.Rules)
+
+	return &QuotaLimiter{
+		store:  store,
+		config: config,
+		now:    time.Now,
+	}, nil
+}
+
+// allow counts the call against every rule matching it, undoing the counts when any quota is exceeded.
+func (q *QuotaLimiter) allow(ctx context.Context, fullMethod string) error {
+	clientID, ok := q.config.KeyExtractor.ClientKey(ctx)
+	if !ok {
+		clientID = unknownClientID
+	}
+
+	tier, _ := q.config.TierExtractor.ClientKey(ctx)
+	now := q.now().In(q.config.Location)
+
+	type count struct {
+		key     QuotaKey
+		expires time.Time
+	}
+
+	var counted []count
+
+	undo := func() {
+		for _, c := range counted {
+			// Best effort, a failed undo only counts the rejected call.
+			_, _ = q.store.Increment(ctx, c.key, -1, c.expires) //nolint:errcheck
+		}
+	}
+
+	for _, r := range q.config.Rules {
+		if !r.matches(fullMethod, tier) {
+			continue
+		}
+
+		window, resets := r.window(now)
+		key := QuotaKey{Client: clientID, Rule: r.Name, Window: window}
+
+		used, err := q.store.Increment(ctx, key, 1, resets)
+		if err != nil {
+			undo()
+
+			return WrapError(codes.Unavailable, err, "quota store unavailable")
+		}
+
+		counted = append(counted, count{key: key, expires: resets})
+
+		if used > r.Limit {
+			undo()
+
+			return withStatusDetails(
+				Error(codes.ResourceExhausted, "quota exceeded for client: %s", map[string]string{
+					"client": clientID,
+					"quota":  r.Name,
+				}),
+				&errdetails.QuotaFailure{
+					Violations: []*errdetails.QuotaFailure_Violation{{
+						Subject:     "client:" + clientID,
+						Description: string(r.Period) + " quota " + r.Name + " of " + strconv.FormatInt(r.Limit, 10) + " requests exceeded",
+					}},
+				},
+				&errdetails.RetryInfo{RetryDelay: durationpb.New(resets.Sub(now))},
+			)
+		}
+	}
+
+	return nil
+}
+
+// Usage returns the usage of the client within the current window of every rule.
+func (q *QuotaLimiter) Usage(ctx context.Context, clientID string) ([]QuotaUsage, error) {
+	now := q.now().In(q.config.Location)
+	usage := make([]QuotaUsage, 0, len(q.config.Rules))
+
+	for _, r := range q.config.Rules {
+		window, resets := r.window(now)
+
+		used, err := q.store.Get(ctx, QuotaKey{Client: clientID, Rule: r.Name, Window: window})
+		if err != nil {
+			return nil, err
+		}
+
+		usage = append(usage, QuotaUsage{
+			Rule:   r.Name,
+			Period: r.Period,
+			Window: window,
+			Used:   used,
+			Limit:  r.Limit,
+			Resets: resets,
+		})
+	}
+
+	return usage, nil
+}
+
+// Reset clears the usage of the client within the current window of the rules, all of them when none is given.
+func (q *QuotaLimiter) Reset(ctx context.Context, clientID string, rules ...string) error {
+	now := q.now().In(q.config.Location)
+
+	for _, r := range q.config.Rules {
+		if len(rules) > 0 && !slices.Contains(rules, r.Name) {
+			continue
+		}
+
+		window, _ := r.window(now)
+
+		if err := q.store.Delete(ctx, QuotaKey{Client: clientID, Rule: r.Name, Window: window}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UnaryServerInterceptor returns a new unary server interceptor that limits the number of requests per client.
+func (q *QuotaLimiter) UnaryServerInterceptor() func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := q.allow(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new stream server interceptor that limits the number of streams created per
+// client.
+func (q *QuotaLimiter) StreamServerInterceptor() func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := q.allow(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// WithQuotaLimiter sets the QuotaLimiter, used to append its unary and stream server interceptors to
+// StageRateLimit, after the GRPCRateLimiter.
+// Apply to GRPC server instances.
+func WithQuotaLimiter(quota *QuotaLimiter) Option {
+	return WithStageInterceptor(StageRateLimit, "quota", quota.UnaryServerInterceptor(), quota.StreamServerInterceptor())
+}
@@ -0,0 +1,344 @@
+package servers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bool64/ctxd"
+)
+
+// Quota store defaults.
+const (
+	// defaultQuotaFlushInterval is the period the FileQuotaStore counters are written when not configured.
+	defaultQuotaFlushInterval = time.Second
+	// defaultQuotaSweepInterval is the period the expired counters are swept when not configured.
+	defaultQuotaSweepInterval = time.Minute
+)
+
+// MemoryQuotaStore is an in process QuotaStore. Counters are lost on restart, mainly used in tests.
+type MemoryQuotaStore struct {
+	mu            sync.Mutex
+	counters      map[QuotaKey]quotaCounter
+	now           func() time.Time
+	sweepInterval time.Duration
+	nextSweep     time.Time
+}
+
+type quotaCounter struct {
+	Client  string    `json:"client"`
+	Rule    string    `json:"rule"`
+	Window  string    `json:"window"`
+	Count   int64     `json:"count"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewMemoryQuotaStore creates a new MemoryQuotaStore.
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{
+		counters:      make(map[QuotaKey]quotaCounter),
+		now:           time.Now,
+		sweepInterval: defaultQuotaSweepInterval,
+	}
+}
+
+// Increment implements QuotaStore.
+func (s *MemoryQuotaStore) Increment(_ context.Context, key QuotaKey, delta int64, expires time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.increment(key, delta, expires), nil
+}
+
+func (s *MemoryQuotaStore) increment(key QuotaKey, delta int64, expires time.Time) int64 {
+	now := s.now()
+
+	// Expired counters belong to past windows, they are swept periodically rather than on every new key.
+	if now.After(s.nextSweep) {
+		s.sweep(now)
+	}
+
+	c := s.counters[key]
+	c.Client, c.Rule, c.Window = key.Client, key.Rule, key.Window
+	c.Count += delta
+	c.Expires = expires
+
+	s.counters[key] = c
+
+	return c.Count
+}
+
+// sweep deletes the expired counters, reporting whether any was.
+func (s *MemoryQuotaStore) sweep(now time.Time) bool {
+	swept := false
+
+	for k, c := range s.counters {
+		if now.After(c.Expires) {
+			delete(s.counters, k)
+
+			swept = true
+		}
+	}
+
+	s.nextSweep = now.Add(s.sweepInterval)
+
+	return swept
+}
+
+// Get implements QuotaStore.
+func (s *MemoryQuotaStore) Get(_ context.Context, key QuotaKey) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.counters[key].Count, nil
+}
+
+// Delete implements QuotaStore.
+func (s *MemoryQuotaStore) Delete(_ context.Context, key QuotaKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, key)
+
+	return nil
+}
+
+// FileQuotaStoreConfig contains configuration options for a FileQuotaStore.
+type FileQuotaStoreConfig struct {
+	// Path is the JSON file the counters are persisted to.
+	Path string `envconfig:"PATH" required:"true"`
+	// FlushInterval is the period the counters are written to the file, 1s by default. Negative writes them on
+	// every change, serializing the calls on the file writes.
+	FlushInterval time.Duration `envconfig:"FLUSH_INTERVAL" default:"1s"`
+	// SweepInterval is the period the expired counters are deleted, 1m by default.
+	SweepInterval time.Duration `envconfig:"SWEEP_INTERVAL" default:"1m"`
+}
+
+// FileQuotaStore is a QuotaStore keeping the counters in memory and persisting them to a JSON file, so they
+// survive restarts of a single replica. The file is replaced atomically on every write.
+//
+// Failed writes do not fail the calls, they are logged and retried on the next flush.
+type FileQuotaStore struct {
+	*MemoryQuotaStore
+
+	config FileQuotaStoreConfig
+	logger ctxd.Logger
+
+	// writeMu serializes the file writes.
+	writeMu sync.Mutex
+	dirty   bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewFileQuotaStore creates a new FileQuotaStore, loading the counters from the file when it exists.
+// The failed writes are logged with logger, when not nil.
+func NewFileQuotaStore(config FileQuotaStoreConfig, logger ctxd.Logger) (*FileQuotaStore, error) {
+	if config.FlushInterval == 0 {
+		config.FlushInterval = defaultQuotaFlushInterval
+	}
+
+	if config.SweepInterval <= 0 {
+		config.SweepInterval = defaultQuotaSweepInterval
+	}
+
+	if logger == nil {
+		logger = ctxd.NoOpLogger{}
+	}
+
+	s := &FileQuotaStore{
+		MemoryQuotaStore: NewMemoryQuotaStore(),
+		config:           config,
+		logger:           logger,
+		done:             make(chan struct{}),
+	}
+
+	s.sweepInterval = config.SweepInterval
+
+	data, err := os.ReadFile(config.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read quota file: %w", err)
+	}
+
+	if len(data) > 0 {
+		var counters []quotaCounter
+
+		if err := json.Unmarshal(data, &counters); err != nil {
+			return nil, fmt.Errorf("decode quota file: %w", err)
+		}
+
+		now := s.now()
+
+		for _, c := range counters {
+			if now.After(c.Expires) {
+				continue
+			}
+
+			s.counters[QuotaKey{Client: c.Client, Rule: c.Rule, Window: c.Window}] = c
+		}
+	}
+
+	s.wg.Add(1)
+
+	go s.loop()
+
+	return s, nil
+}
+
+// Increment implements QuotaStore.
+func (s *FileQuotaStore) Increment(_ context.Context, key QuotaKey, delta int64, expires time.Time) (int64, error) {
+	s.mu.Lock()
+	count := s.increment(key, delta, expires)
+	s.dirty = true
+	s.mu.Unlock()
+
+	s.written()
+
+	return count, nil
+}
+
+// Delete implements QuotaStore.
+func (s *FileQuotaStore) Delete(_ context.Context, key QuotaKey) error {
+	s.mu.Lock()
+	delete(s.counters, key)
+	s.dirty = true
+	s.mu.Unlock()
+
+	s.written()
+
+	return nil
+}
+
+// written flushes the counters when they are written on every change.
+func (s *FileQuotaStore) written() {
+	if s.config.FlushInterval > 0 {
+		return
+	}
+
+	s.flush()
+}
+
+// flush writes the counters, logging the failure.
+func (s *FileQuotaStore) flush() {
+	if err := s.Flush(); err != nil {
+		s.logger.Error(context.Background(), "failed to persist quota counters", "error", err, "path", s.config.Path)
+	}
+}
+
+// Flush writes the counters to the file when they changed since the last write.
+func (s *FileQuotaStore) Flush() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.Lock()
+
+	if !s.dirty {
+		s.mu.Unlock()
+
+		return nil
+	}
+
+	counters := make([]quotaCounter, 0, len(s.counters))
+
+	for _, c := range s.counters {
+		counters = append(counters, c)
+	}
+
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err := s.write(counters); err != nil {
+		// The counters stay dirty, so the write is retried on the next flush.
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileQuotaStore) write(counters []quotaCounter) error {
+	data, err := json.Marshal(counters)
+	if err != nil {
+		return fmt.Errorf("encode quota file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.config.Path), filepath.Base(s.config.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("write quota file: %w", err)
+	}
+
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close() //nolint:errcheck
+
+		return fmt.Errorf("write quota file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write quota file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.config.Path); err != nil {
+		return fmt.Errorf("write quota file: %w", err)
+	}
+
+	return nil
+}
+
+// loop flushes the counters periodically, unless they are written on every change, and sweeps the expired
+// ones, so they are deleted from the file even when no call is made.
+func (s *FileQuotaStore) loop() {
+	defer s.wg.Done()
+
+	var flushC <-chan time.Time
+
+	if s.config.FlushInterval > 0 {
+		flush := time.NewTicker(s.config.FlushInterval)
+		defer flush.Stop()
+
+		flushC = flush.C
+	}
+
+	sweep := time.NewTicker(s.config.SweepInterval)
+	defer sweep.Stop()
+
+	for {
+		select {
+		case <-flushC:
+			s.flush()
+		case <-sweep.C:
+			s.mu.Lock()
+			swept := s.sweep(s.now())
+			s.dirty = s.dirty || swept
+			s.mu.Unlock()
+
+			if swept {
+				s.written()
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close stops the periodic flush and sweep and writes the pending changes.
+func (s *FileQuotaStore) Close() error {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+
+	s.wg.Wait()
+
+	return s.Flush()
+}
@@ -0,0 +1,152 @@
+package servers_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestQuotaLimiter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+
+	store, err := servers.NewFileQuotaStore(servers.FileQuotaStoreConfig{Path: path}, nil)
+	require.NoError(t, err)
+
+	config := servers.QuotaConfig{
+		Rules: []servers.QuotaRule{
+			{Name: "greeter-daily", Methods: []string{"/helloworld.Greeter/*"}, Period: servers.QuotaDaily, Limit: 2},
+			{Name: "all-monthly", Period: servers.QuotaMonthly, Limit: 10},
+		},
+	}
+
+	quota, err := servers.NewQuotaLimiter(store, config)
+	require.NoError(t, err)
+
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithQuotaLimiter(quota))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewGreeterClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "client-id", "test-client")
+
+	for i := 0; i < 2; i++ {
+		_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+		require.NoError(t, err)
+	}
+
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	require.Error(t, err)
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+
+	var quotaFailure *errdetails.QuotaFailure
+
+	for _, d := range st.Details() {
+		if qf, ok := d.(*errdetails.QuotaFailure); ok {
+			quotaFailure = qf
+		}
+	}
+
+	require.NotNil(t, quotaFailure)
+	assert.Equal(t, "client:test-client", quotaFailure.GetViolations()[0].GetSubject())
+
+	// Rejected calls are not counted.
+	usage, err := quota.Usage(ctx, "test-client")
+	require.NoError(t, err)
+	require.Len(t, usage, 2)
+	assert.Equal(t, int64(2), usage[0].Used)
+	assert.Equal(t, int64(2), usage[1].Used)
+	assert.Equal(t, time.Now().UTC().Format("2006-01-02"), usage[0].Window)
+	assert.Equal(t, time.Now().UTC().Format("2006-01"), usage[1].Window)
+
+	// The usage survives a restart.
+	require.NoError(t, store.Close())
+
+	store, err = servers.NewFileQuotaStore(servers.FileQuotaStoreConfig{Path: path}, nil)
+	require.NoError(t, err)
+
+	quota, err = servers.NewQuotaLimiter(store, config)
+	require.NoError(t, err)
+
+	usage, err = quota.Usage(ctx, "test-client")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), usage[0].Used)
+
+	require.NoError(t, quota.Reset(ctx, "test-client", "greeter-daily"))
+
+	usage, err = quota.Usage(ctx, "test-client")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), usage[0].Used)
+	assert.Equal(t, int64(2), usage[1].Used)
+
+	_, err = servers.NewQuotaLimiter(store, servers.QuotaConfig{
+		Rules: []servers.QuotaRule{{Name: "invalid", Period: "yearly"}},
+	})
+	require.ErrorIs(t, err, servers.ErrInvalidQuotaRule)
+}
+
+func TestFileQuotaStore_writeFailure(t *testing.T) {
+	var buff syncBuffer
+
+	// The directory does not exist, so every write fails.
+	store, err := servers.NewFileQuotaStore(servers.FileQuotaStoreConfig{
+		Path:          filepath.Join(t.TempDir(), "missing", "quota.json"),
+		FlushInterval: -1,
+	}, newTestLogger(&buff))
+	require.NoError(t, err)
+
+	key := servers.QuotaKey{Client: "test-client", Rule: "daily", Window: "2026-01-01"}
+
+	count, err := store.Increment(context.Background(), key, 1, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.NotNil(t, findLogLine(logLines(t, &buff), "failed to persist quota counters"))
+	require.Error(t, store.Close())
+}
+
+func TestFileQuotaStore_sweep(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+
+	store, err := servers.NewFileQuotaStore(servers.FileQuotaStoreConfig{
+		Path:          path,
+		FlushInterval: 5 * time.Millisecond,
+		SweepInterval: 5 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+
+	defer store.Close() //nolint:errcheck
+
+	key := servers.QuotaKey{Client: "test-client", Rule: "daily", Window: "2026-01-01"}
+
+	_, err = store.Increment(context.Background(), key, 1, time.Now().Add(20*time.Millisecond))
+	require.NoError(t, err)
+
+	// The expired counter is deleted from the file while the store is idle.
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(path)
+
+		return err == nil && string(data) == "[]"
+	}, time.Second, 5*time.Millisecond)
+
+	count, err := store.Get(context.Background(), key)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
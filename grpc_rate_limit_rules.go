@@ -0,0 +1,102 @@
+package servers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+)
+
+// ErrInvalidRateLimitRule is returned when a RateLimitRule is not valid.
+var ErrInvalidRateLimitRule = errors.New("invalid rate limit rule")
+
+// RateLimitRule sets the limits of the calls matching the method patterns and the client tiers.
+type RateLimitRule struct {
+	// Name identifies the rule buckets, rules must have a unique name.
+	Name string `json:"name"`
+	// Methods are the full method glob patterns the rule applies to, e.g. "/reports.Exporter/*".
+	// Empty matches any method.
+	Methods []string `json:"methods,omitempty"`
+	// Tiers are the client tiers the rule applies to, e.g. "premium". Empty matches any tier.
+	Tiers []string `json:"tiers,omitempty"`
+	// RPS is the requests per second allowed per client.
+	RPS float64 `json:"rps"`
+	// Burst is the maximum number of requests allowed at once per client.
+	Burst int `json:"burst"`
+}
+
+func (r RateLimitRule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("%w: missing name", ErrInvalidRateLimitRule)
+	}
+
+	if r.RPS < 0 || r.Burst < 0 {
+		return fmt.Errorf("%w: %s: negative rps or burst", ErrInvalidRateLimitRule, r.Name)
+	}
+
+	for _, p := range r.Methods {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("%w: %s: method %q: %v", ErrInvalidRateLimitRule, r.Name, p, err) //nolint:errorlint
+		}
+	}
+
+	return nil
+}
+
+func (r RateLimitRule) matches(fullMethod, tier string) bool {
+	if len(r.Methods) > 0 && !matchFullMethod(r.Methods, fullMethod) {
+		return false
+	}
+
+	return len(r.Tiers) == 0 || slices.Contains(r.Tiers, tier)
+}
+
+// ParseRateLimitRules parses the JSON encoded rule table, validating the rules.
+func ParseRateLimitRules(data []byte) ([]RateLimitRule, error) {
+	var rules []RateLimitRule
+
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRateLimitRule, err) //nolint:errorlint
+	}
+
+	if err := validateRateLimitRules(rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func validateRateLimitRules(rules []RateLimitRule) error {
+	names := make(map[string]bool, len(rules))
+
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return err
+		}
+
+		if names[r.Name] {
+			return fmt.Errorf("%w: %s: duplicated name", ErrInvalidRateLimitRule, r.Name)
+		}
+
+		names[r.Name] = true
+	}
+
+	return nil
+}
+
+// PrincipalTierExtractor identifies the client tier by the authenticated principal, see ContextWithPrincipal,
+// using the principal to tier mapping.
+func PrincipalTierExtractor(tiers map[string]string) ClientKeyExtractor {
+	return ClientKeyExtractorFunc(func(ctx context.Context) (string, bool) {
+		principal, ok := PrincipalFromContext(ctx)
+		if !ok {
+			return "", false
+		}
+
+		tier, ok := tiers[principal]
+
+		return tier, ok
+	})
+}
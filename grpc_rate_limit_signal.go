@@ -0,0 +1,108 @@
+package servers
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Rate limit header metadata keys, sent on every limited call. GRPCRest translates them to the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset HTTP headers.
+const (
+	rateLimitLimitKey     = "ratelimit-limit"
+	rateLimitRemainingKey = "ratelimit-remaining"
+	rateLimitResetKey     = "ratelimit-reset"
+)
+
+// rateLimitDecision is the outcome of accounting a call against the quota of a client.
+type rateLimitDecision struct {
+	clientID string
+	allowed  bool
+
+	// limit is the number of calls allowed at once, remaining the ones left.
+	limit     int
+	remaining int
+	// reset is the time until the quota is fully restored.
+	reset time.Duration
+	// retryAfter is the time until the call would be allowed, when rejected.
+	retryAfter time.Duration
+}
+
+// takeToken takes a token from the limiter, leaving it untouched when the call is rejected.
+func takeToken(limiter *rate.Limiter, clientID string) rateLimitDecision {
+	now := time.Now()
+
+	d := rateLimitDecision{
+		clientID: clientID,
+		limit:    limiter.Burst(),
+	}
+
+	if r := limiter.ReserveN(now, 1); r.OK() {
+		if delay := r.DelayFrom(now); delay > 0 {
+			r.CancelAt(now)
+
+			d.retryAfter = delay
+		} else {
+			d.allowed = true
+		}
+	}
+
+	tokens := limiter.TokensAt(now)
+
+	d.remaining = max(0, int(tokens))
+
+	if l := limiter.Limit(); l > 0 && l != rate.Inf && tokens < float64(d.limit) {
+		d.reset = time.Duration((float64(d.limit) - tokens) / float64(l) * float64(time.Second))
+	}
+
+	return d
+}
+
+// metadata returns the rate limit header metadata of the decision.
+func (d rateLimitDecision) metadata() metadata.MD {
+	return metadata.Pairs(
+		rateLimitLimitKey, strconv.Itoa(d.limit),
+		rateLimitRemainingKey, strconv.Itoa(d.remaining),
+		rateLimitResetKey, strconv.Itoa(ceilSeconds(d.reset)),
+	)
+}
+
+// err returns the ResourceExhausted error of a rejected call, carrying RetryInfo and QuotaFailure details.
+func (d rateLimitDecision) err(msg string) error {
+	err := Error(codes.ResourceExhausted, msg, map[string]string{"client": d.clientID})
+
+	details := []protoadapt.MessageV1{
+		&errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{{
+				Subject:     "client:" + d.clientID,
+				Description: "rate limit of " + strconv.Itoa(d.limit) + " requests exceeded",
+			}},
+		},
+	}
+
+	if d.retryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(d.retryAfter)})
+	}
+
+	return withStatusDetails(err, details...)
+}
+
+// setRateLimitHeader sends the rate limit header metadata of the decision with the unary call response.
+func setRateLimitHeader(ctx context.Context, d rateLimitDecision) {
+	// Fails only when called outside a server call or after the header was sent.
+	_ = grpc.SetHeader(ctx, d.metadata()) //nolint:errcheck
+}
+
+// ceilSeconds returns the duration in whole seconds, rounded up.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
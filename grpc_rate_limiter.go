@@ -2,108 +2,274 @@ package servers
 
 import (
 	"context"
-	"sync"
+	"slices"
+	"sync/atomic"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/metadata"
 )
 
-// getClientID extracts the client ID from the context metadata.
-func getClientID(ctx context.Context) string {
-	// Check if metadata is present in the context
-	md, ok := metadata.FromIncomingContext(ctx)
+// unknownClientID is the client ID of the calls the client can not be identified.
+const unknownClientID = "unknown-client"
 
-	const unknown = "unknown-client"
+// rateLimitExceededMsg is the message of the calls rejected by the rate limiters.
+const rateLimitExceededMsg = "rate limit exceeded for client: %s"
 
-	if !ok {
-		return unknown // Default if no metadata is found
+// WithRateLimiter sets the rate limiter for the gRPC server.
+//
+// Deprecated: use WithGRPCRateLimiter, WithRateLimiter is an alias of it. Combining both options used to
+// ignore the second one, Start now fails with ErrGRPCRateLimiterSet.
+func WithRateLimiter(observer GRPCObserver) Option {
+	return WithGRPCRateLimiter(observer)
+}
+
+// RateLimiterOption sets up a PerClientRateLimiter.
+type RateLimiterOption func(r *PerClientRateLimiter)
+
+// WithStreamMessageRate limits the messages received per second within each stream.
+// By default, only the stream creation is limited.
+func WithStreamMessageRate(rps float64, burst int) RateLimiterOption {
+	return func(r *PerClientRateLimiter) {
+		r.streamMsgRPS = rps
+		r.streamMsgBurst = burst
 	}
+}
 
-	// Look for a specific key in the metadata (e.g., "client-id")
-	if clientIDs, exists := md["client-id"]; exists && len(clientIDs) > 0 {
-		return clientIDs[0] // Return the first client ID if available
+// WithKeyExtractor sets how the client of the call is identified. Defaults to the "client-id" metadata key.
+func WithKeyExtractor(extractor ClientKeyExtractor) RateLimiterOption {
+	return func(r *PerClientRateLimiter) {
+		r.keyExtractor = extractor
 	}
+}
 
-	return unknown // Default if "client-id" is not found
+// WithUnidentifiedRate sets the requests per second and burst limit shared by all the calls whose client can
+// not be identified and no rule matches. A zero burst rejects them. By default, they share a bucket with the per
+// client limits. The unidentified calls a rule matches share the bucket of the rule.
+func WithUnidentifiedRate(rps float64, burst int) RateLimiterOption {
+	return func(r *PerClientRateLimiter) {
+		r.unidentified = rate.NewLimiter(rate.Limit(rps), burst)
+	}
 }
 
-// WithRateLimiter sets the rate limiter for the gRPC server.
-func WithRateLimiter(observer GRPCObserver) Option {
-	return func(srv any) {
-		s, ok := srv.(*GRPC)
-		if !ok {
-			// skip does not apply to this instance.
-			return
-		}
+// WithTierExtractor sets how the tier of the client is identified to match the RateLimitRule tiers.
+// Defaults to the "client-tier" metadata key.
+func WithTierExtractor(extractor ClientKeyExtractor) RateLimiterOption {
+	return func(r *PerClientRateLimiter) {
+		r.tierExtractor = extractor
+	}
+}
 
-		if s.options.observer != nil {
-			return
+// WithRules sets the rule table, see PerClientRateLimiter.SetRules.
+// When the rules are invalid, the error is returned by the Start of the GRPC server the limiter is set to.
+func WithRules(rules ...RateLimitRule) RateLimiterOption {
+	return func(r *PerClientRateLimiter) {
+		if err := r.SetRules(rules); err != nil {
+			r.errs = append(r.errs, err)
 		}
-
-		s.options.observer = observer
-
-		s.options.serverOpts = append(
-			s.options.serverOpts,
-			grpc.ChainUnaryInterceptor(observer.UnaryServerInterceptor()),
-			grpc.ChainStreamInterceptor(observer.StreamServerInterceptor()),
-		)
 	}
 }
 
 // PerClientRateLimiter is a gRPC interceptor that limits the number of requests per client.
+// Streams count as one request on creation.
+//
+// The client buckets are evicted when idle or when the maximum number of tracked clients is reached, see
+// WithIdleTTL and WithMaxClients. It is a prometheus.Collector exporting the tracked clients and evictions.
 type PerClientRateLimiter struct {
-	clients map[string]*rate.Limiter
-	mu      sync.Mutex
-	rps     float64
-	burst   int
+	store *bucketStore
+	rps   float64
+	burst int
+
+	keyExtractor  ClientKeyExtractor
+	tierExtractor ClientKeyExtractor
+	unidentified  *rate.Limiter
+
+	rules atomic.Pointer[[]RateLimitRule]
+
+	streamMsgRPS   float64
+	streamMsgBurst int
+
+	// errs are the errors of the misused options, returned by the GRPC server Start.
+	errs []error
 }
 
 // NewPerClientRateLimiter creates a new PerClientRateLimiter with the given requests per second and burst limit.
-func NewPerClientRateLimiter(rps float64, burst int) *PerClientRateLimiter {
-	return &PerClientRateLimiter{
-		clients: make(map[string]*rate.Limiter),
-		rps:     rps,
-		burst:   burst,
+func NewPerClientRateLimiter(rps float64, burst int, opts ...RateLimiterOption) *PerClientRateLimiter {
+	r := &PerClientRateLimiter{
+		store:         newBucketStore(),
+		rps:           rps,
+		burst:         burst,
+		keyExtractor:  MetadataKeyExtractor("client-id"),
+		tierExtractor: MetadataKeyExtractor("client-tier"),
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	r.store.init()
+
+	return r
+}
+
+// SetRules replaces the rule table. It is safe to be called while the server is running.
+//
+// Rules are evaluated in order and the first rule matching the method and the client tier sets the limits of the
+// call. Each rule has its own bucket per client. Calls no rule matches use the limiter requests per second and
+// burst. Buckets of rules kept by name preserve their state, adopting the new limits.
+func (r *PerClientRateLimiter) SetRules(rules []RateLimitRule) error {
+	if err := validateRateLimitRules(rules); err != nil {
+		return err
+	}
+
+	rules = slices.Clone(rules)
+
+	r.rules.Store(&rules)
+
+	return nil
+}
+
+// Rules returns the current rule table.
+func (r *PerClientRateLimiter) Rules() []RateLimitRule {
+	rules := r.rules.Load()
+	if rules == nil {
+		return nil
 	}
+
+	return slices.Clone(*rules)
+}
+
+// limits returns the bucket name and limits of the call.
+func (r *PerClientRateLimiter) limits(ctx context.Context, fullMethod string) (string, float64, int) {
+	rules := r.rules.Load()
+	if rules == nil {
+		return "", r.rps, r.burst
+	}
+
+	tier, _ := r.tierExtractor.ClientKey(ctx)
+
+	for _, rule := range *rules {
+		if rule.matches(fullMethod, tier) {
+			return rule.Name, rule.RPS, rule.Burst
+		}
+	}
+
+	return "", r.rps, r.burst
+}
+
+// Describe implements prometheus.Collector, describing the tracked clients and evictions metrics.
+func (r *PerClientRateLimiter) Describe(ch chan<- *prometheus.Desc) {
+	r.store.Describe(ch)
 }
 
-func (r *PerClientRateLimiter) getLimiter(clientID string) *rate.Limiter {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// Collect implements prometheus.Collector, collecting the tracked clients and evictions metrics.
+func (r *PerClientRateLimiter) Collect(ch chan<- prometheus.Metric) {
+	r.store.Collect(ch)
+}
 
-	if limiter, exists := r.clients[clientID]; exists {
-		return limiter
+// allow takes a token from the bucket of the client of the call, returning the decision.
+func (r *PerClientRateLimiter) allow(ctx context.Context, fullMethod string) rateLimitDecision {
+	clientID, ok := r.keyExtractor.ClientKey(ctx)
+	if !ok {
+		clientID = unknownClientID
 	}
 
-	limiter := rate.NewLimiter(rate.Limit(r.rps), r.burst)
-	r.clients[clientID] = limiter
+	name, rps, burst := r.limits(ctx, fullMethod)
+
+	var limiter *rate.Limiter
+
+	switch {
+	case name != "":
+		limiter = r.store.get(name+"\x00"+clientID, rps, burst)
+	case !ok && r.unidentified != nil:
+		limiter = r.unidentified
+	default:
+		limiter = r.store.get(clientID, rps, burst)
+	}
 
-	return limiter
+	return takeToken(limiter, clientID)
 }
 
 // UnaryServerInterceptor returns a new unary server interceptor that limits the number of requests per client.
+//
+// The remaining quota is sent as ratelimit-limit, ratelimit-remaining and ratelimit-reset header metadata.
+// Rejected calls fail with ResourceExhausted, carrying RetryInfo and QuotaFailure details.
 func (r *PerClientRateLimiter) UnaryServerInterceptor() func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
-		clientID := getClientID(ctx) // Extract client ID from metadata or IP
-		limiter := r.getLimiter(clientID)
+		d := r.allow(ctx, info.FullMethod)
+
+		setRateLimitHeader(ctx, d)
 
-		if !limiter.Allow() {
-			return nil, Error(codes.ResourceExhausted, "rate limit exceeded for client: %s", map[string]string{"client": clientID})
+		if !d.allowed {
+			return nil, d.err(rateLimitExceededMsg)
 		}
 
 		return handler(ctx, req)
 	}
 }
 
+// StreamServerInterceptor returns a new stream server interceptor that limits the number of streams created per
+// client and, when WithStreamMessageRate is set, the messages received per second within each stream.
+func (r *PerClientRateLimiter) StreamServerInterceptor() func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		d := r.allow(ss.Context(), info.FullMethod)
+
+		_ = ss.SetHeader(d.metadata()) //nolint:errcheck
+
+		if !d.allowed {
+			return d.err(rateLimitExceededMsg)
+		}
+
+		if r.streamMsgRPS <= 0 {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &rateLimitedServerStream{
+			ServerStream: ss,
+			clientID:     d.clientID,
+			limiter:      rate.NewLimiter(rate.Limit(r.streamMsgRPS), r.streamMsgBurst),
+		})
+	}
+}
+
+// rateLimitedServerStream limits the messages received within the stream.
+type rateLimitedServerStream struct {
+	grpc.ServerStream
+
+	clientID string
+	limiter  *rate.Limiter
+}
+
+func (s *rateLimitedServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	// The message is accounted once received, so the rate is not consumed while waiting for the client.
+	if d := takeToken(s.limiter, s.clientID); !d.allowed {
+		return d.err("stream message rate limit exceeded for client: %s")
+	}
+
+	return nil
+}
+
 // GRPCRateLimiter interface implemented by anything wants to append rate limiter in the server
 // thro UnaryServerInterceptor.
 type GRPCRateLimiter interface {
 	UnaryServerInterceptor() func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error)
 }
 
-// WithGRPCRateLimiter sets the GRPCRateLimiter, used to append UnaryServerInterceptor.
+// GRPCStreamRateLimiter interface implemented by any GRPCRateLimiter that limits streams as well
+// thro StreamServerInterceptor.
+type GRPCStreamRateLimiter interface {
+	StreamServerInterceptor() func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error
+}
+
+// WithGRPCRateLimiter sets the GRPCRateLimiter, used to append UnaryServerInterceptor and, when the limiter
+// implements GRPCStreamRateLimiter, StreamServerInterceptor.
+//
+// The rate limiter runs in StageRateLimit. Setting the rate limiter more than once keeps the first one, Start
+// fails with ErrGRPCRateLimiterSet.
 // Apply to GRPC server instances.
 func WithGRPCRateLimiter(limiter GRPCRateLimiter) Option {
 	return func(srv any) {
@@ -114,14 +280,15 @@ func WithGRPCRateLimiter(limiter GRPCRateLimiter) Option {
 		}
 
 		if s.options.limiter != nil {
+			s.options.errs = append(s.options.errs, ErrGRPCRateLimiterSet)
+
 			return
 		}
 
 		s.options.limiter = limiter
 
-		s.options.serverOpts = append(
-			s.options.serverOpts,
-			grpc.ChainUnaryInterceptor(limiter.UnaryServerInterceptor()),
-		)
+		if r, ok := limiter.(*PerClientRateLimiter); ok {
+			s.options.errs = append(s.options.errs, r.errs...)
+		}
 	}
 }
@@ -0,0 +1,239 @@
+package servers
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// RateLimitStore keeps the sliding window counters of the DistributedRateLimiter, shared by all the replicas.
+type RateLimitStore interface {
+	// IncrementWindow counts the call in the counter of the current window key, expiring it after ttl, when the
+	// sliding count, the current window counter plus the previous window counter weighted by prevWeight, rounded up,
+	// is within the limit with the call. It returns the sliding count, including the call when it is allowed.
+	IncrementWindow(
+		ctx context.Context,
+		key, prevKey string,
+		prevWeight float64,
+		limit int64,
+		ttl time.Duration,
+	) (count int64, allowed bool, err error)
+}
+
+// DistributedRateLimiterConfig contains configuration options for a DistributedRateLimiter.
+type DistributedRateLimiterConfig struct {
+	// RPS is the requests per second allowed per client.
+	RPS float64
+	// Burst is the maximum number of requests allowed at once per client.
+	Burst int
+	// FailOpen allows the calls when the store is unavailable, otherwise they are rejected with Unavailable.
+	FailOpen bool
+	// Prefix is prepended to the store keys. Defaults to "ratelimit".
+	Prefix string
+	// KeyExtractor identifies the client of the call. Defaults to the "client-id" metadata key.
+	KeyExtractor ClientKeyExtractor
+}
+
+// DistributedRateLimiter is a GRPCRateLimiter that limits the number of requests per client across replicas,
+// using a sliding window counter kept in a RateLimitStore.
+//
+// The window is Burst/RPS long and allows Burst requests. The count is the current window counter plus the
+// previous window counter weighted by its overlap with the sliding window. Only the allowed calls are counted, so
+// the clients retrying while rejected regain their quota as the window slides.
+type DistributedRateLimiter struct {
+	store  RateLimitStore
+	config DistributedRateLimiterConfig
+	window time.Duration
+	now    func() time.Time
+}
+
+// NewDistributedRateLimiter creates a new DistributedRateLimiter backed by the store.
+func NewDistributedRateLimiter(store RateLimitStore, config DistributedRateLimiterConfig) *DistributedRateLimiter {
+	if config.Prefix == "" {
+		config.Prefix = "ratelimit"
+	}
+
+	if config.KeyExtractor == nil {
+		config.KeyExtractor = MetadataKeyExtractor("client-id")
+	}
+
+	window := time.Second
+
+	if config.RPS > 0 && config.Burst > 0 {
+		window = time.Duration(float64(config.Burst) / config.RPS * float64(time.Second))
+	}
+
+	return &DistributedRateLimiter{
+		store:  store,
+		config: config,
+		window: window,
+		now:    time.Now,
+	}
+}
+
+// allow counts the call in the window of the client, returning the decision. The error is set when the store
+// failed and the limiter is not failing open, the decision is unknown when it failed open.
+func (r *DistributedRateLimiter) allow(ctx context.Context) (rateLimitDecision, bool, error) {
+	clientID, ok := r.config.KeyExtractor.ClientKey(ctx)
+	if !ok {
+		clientID = unknownClientID
+	}
+
+	now := r.now()
+	idx := now.UnixNano() / int64(r.window)
+	elapsed := float64(now.UnixNano()%int64(r.window)) / float64(r.window)
+
+	// The client ID is a hash tag, so the window keys of the client belong to the same Redis cluster slot.
+	key := r.config.Prefix + ":{" + clientID + "}:"
+
+	count, allowed, err := r.store.IncrementWindow(ctx,
+		key+strconv.FormatInt(idx, 10),
+		key+strconv.FormatInt(idx-1, 10),
+		1-elapsed,
+		int64(r.config.Burst),
+		2*r.window,
+	)
+	if err != nil {
+		if r.config.FailOpen {
+			return rateLimitDecision{clientID: clientID, allowed: true}, false, nil
+		}
+
+		return rateLimitDecision{}, false, WrapError(codes.Unavailable, err, "rate limiter unavailable")
+	}
+
+	// The previous window weight fades until the end of the current one, so that is when the quota is restored.
+	untilNext := time.Duration((1 - elapsed) * float64(r.window))
+
+	d := rateLimitDecision{
+		clientID:  clientID,
+		allowed:   allowed,
+		limit:     r.config.Burst,
+		remaining: max(0, r.config.Burst-int(count)),
+		reset:     untilNext,
+	}
+
+	if !d.allowed {
+		d.retryAfter = untilNext
+	}
+
+	return d, true, nil
+}
+
+// UnaryServerInterceptor returns a new unary server interceptor that limits the number of requests per client.
+//
+// The remaining quota is sent as ratelimit-limit, ratelimit-remaining and ratelimit-reset header metadata.
+// Rejected calls fail with ResourceExhausted, carrying RetryInfo and QuotaFailure details.
+func (r *DistributedRateLimiter) UnaryServerInterceptor() func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		d, known, err := r.allow(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if known {
+			setRateLimitHeader(ctx, d)
+		}
+
+		if !d.allowed {
+			return nil, d.err(rateLimitExceededMsg)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new stream server interceptor that limits the number of streams created per
+// client.
+func (r *DistributedRateLimiter) StreamServerInterceptor() func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		d, known, err := r.allow(ss.Context())
+		if err != nil {
+			return err
+		}
+
+		if known {
+			_ = ss.SetHeader(d.metadata()) //nolint:errcheck
+		}
+
+		if !d.allowed {
+			return d.err(rateLimitExceededMsg)
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// MemoryRateLimitStore is an in process RateLimitStore. Counters are not shared across replicas, mainly used in
+// tests and single replica deployments.
+type MemoryRateLimitStore struct {
+	mu       sync.Mutex
+	counters map[string]memoryCounter
+	now      func() time.Time
+	sweep    time.Time
+}
+
+type memoryCounter struct {
+	count   int64
+	expires time.Time
+}
+
+// NewMemoryRateLimitStore creates a new MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		counters: make(map[string]memoryCounter),
+		now:      time.Now,
+	}
+}
+
+// IncrementWindow implements RateLimitStore.
+func (s *MemoryRateLimitStore) IncrementWindow(
+	_ context.Context,
+	key, prevKey string,
+	prevWeight float64,
+	limit int64,
+	ttl time.Duration,
+) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	// Expired counters are swept at most once per ttl.
+	if now.After(s.sweep) {
+		for k, c := range s.counters {
+			if now.After(c.expires) {
+				delete(s.counters, k)
+			}
+		}
+
+		s.sweep = now.Add(ttl)
+	}
+
+	c := s.counters[key]
+	if now.After(c.expires) {
+		c.count = 0
+	}
+
+	var prev int64
+
+	if p, ok := s.counters[prevKey]; ok && !now.After(p.expires) {
+		prev = p.count
+	}
+
+	count := int64(math.Ceil(float64(prev)*prevWeight)) + c.count + 1
+	if count > limit {
+		return count - 1, false, nil
+	}
+
+	c.count++
+	c.expires = now.Add(ttl)
+
+	s.counters[key] = c
+
+	return count, true, nil
+}
@@ -0,0 +1,304 @@
+package servers_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestDistributedRateLimiter(t *testing.T) {
+	for name, store := range map[string]servers.RateLimitStore{
+		"memory": servers.NewMemoryRateLimitStore(),
+		"redis":  servers.NewRedisRateLimitStore(servers.RedisRateLimitStoreConfig{Addr: miniredis.RunT(t).Addr()}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			limiter := servers.NewDistributedRateLimiter(store, servers.DistributedRateLimiterConfig{
+				RPS:   0.01,
+				Burst: 2,
+			})
+
+			srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(limiter))
+			require.NoErrorf(t, err, "start GRPC: %v", err)
+
+			defer srv.Stop()
+
+			c := testdata.NewGreeterClient(dialGRPC(t, addr))
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			ctx = metadata.AppendToOutgoingContext(ctx, "client-id", "test-client")
+
+			for i := 0; i < 2; i++ {
+				_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+				require.NoError(t, err)
+			}
+
+			_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		})
+	}
+}
+
+func TestDistributedRateLimiter_StoreUnavailable(t *testing.T) {
+	m := miniredis.RunT(t)
+	addr := m.Addr()
+
+	m.Close()
+
+	for _, failOpen := range []bool{true, false} {
+		t.Run(fmt.Sprintf("fail open %t", failOpen), func(t *testing.T) {
+			limiter := servers.NewDistributedRateLimiter(
+				servers.NewRedisRateLimitStore(servers.RedisRateLimitStoreConfig{Addr: addr}),
+				servers.DistributedRateLimiterConfig{
+					RPS:      1,
+					Burst:    1,
+					FailOpen: failOpen,
+				},
+			)
+
+			srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(limiter))
+			require.NoErrorf(t, err, "start GRPC: %v", err)
+
+			defer srv.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			_, err = testdata.NewGreeterClient(dialGRPC(t, addr)).SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+
+			if failOpen {
+				require.NoError(t, err)
+			} else {
+				assert.Equal(t, codes.Unavailable, status.Code(err))
+			}
+		})
+	}
+}
+
+func TestDistributedRateLimiter_rejectedNotCounted(t *testing.T) {
+	// The window is 100ms long.
+	limiter := servers.NewDistributedRateLimiter(servers.NewMemoryRateLimitStore(), servers.DistributedRateLimiterConfig{
+		RPS:   20,
+		Burst: 2,
+	})
+
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(limiter))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewGreeterClient(dialGRPC(t, addr))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "client-id", "test-client")
+
+	// The client retrying while rejected regains its quota as the window slides.
+	var allowed int
+
+	for deadline := time.Now().Add(300 * time.Millisecond); time.Now().Before(deadline); {
+		if _, err := c.SayHello(ctx, &testdata.HelloRequest{Name: "test"}); err == nil {
+			allowed++
+		} else {
+			require.Equal(t, codes.ResourceExhausted, status.Code(err))
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	assert.Greater(t, allowed, 2)
+}
+
+func TestRedisRateLimitStore_IncrementWindow(t *testing.T) {
+	m := miniredis.RunT(t)
+	m.RequireAuth("secret")
+
+	store := servers.NewRedisRateLimitStore(servers.RedisRateLimitStoreConfig{Addr: m.Addr(), Password: "secret"})
+
+	defer store.Close() //nolint:errcheck
+
+	ctx := context.Background()
+
+	incr := func(key, prevKey string, prevWeight float64) (int64, bool) {
+		t.Helper()
+
+		count, allowed, err := store.IncrementWindow(ctx, key, prevKey, prevWeight, 2, time.Second)
+		require.NoError(t, err)
+
+		return count, allowed
+	}
+
+	t.Run("window", func(t *testing.T) {
+		count, allowed := incr("w1", "w0", 0)
+		assert.Equal(t, int64(1), count)
+		assert.True(t, allowed)
+		assert.Equal(t, time.Second, m.TTL("w1"))
+
+		count, allowed = incr("w1", "w0", 0)
+		assert.Equal(t, int64(2), count)
+		assert.True(t, allowed)
+
+		// The rejected call is not counted.
+		count, allowed = incr("w1", "w0", 0)
+		assert.Equal(t, int64(2), count)
+		assert.False(t, allowed)
+		m.CheckGet(t, "w1", "2")
+	})
+
+	t.Run("rollover", func(t *testing.T) {
+		// Half of the previous window counts, 2*0.5 + 1.
+		count, allowed := incr("w2", "w1", 0.5)
+		assert.Equal(t, int64(2), count)
+		assert.True(t, allowed)
+
+		count, allowed = incr("w2", "w1", 0.5)
+		assert.Equal(t, int64(2), count)
+		assert.False(t, allowed)
+		m.CheckGet(t, "w2", "1")
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		m.FastForward(time.Second)
+
+		assert.False(t, m.Exists("w1"))
+		assert.False(t, m.Exists("w2"))
+
+		count, allowed := incr("w3", "w2", 1)
+		assert.Equal(t, int64(1), count)
+		assert.True(t, allowed)
+	})
+
+	t.Run("error reply", func(t *testing.T) {
+		noAuth := servers.NewRedisRateLimitStore(servers.RedisRateLimitStoreConfig{Addr: m.Addr()})
+
+		defer noAuth.Close() //nolint:errcheck
+
+		_, _, err := noAuth.IncrementWindow(ctx, "w4", "w3", 0, 2, time.Second)
+		require.ErrorIs(t, err, servers.ErrRedis)
+	})
+}
+
+// runRESPServer serves the replies in order, one per command, on a single connection.
+// The names of the received commands are sent to the returned channel.
+func runRESPServer(t *testing.T, replies ...string) (string, <-chan string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = l.Close() //nolint:errcheck
+	})
+
+	cmds := make(chan string, len(replies))
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		defer c.Close() //nolint:errcheck
+
+		r := bufio.NewReader(c)
+
+		for _, reply := range replies {
+			// Each command is an array of bulk strings.
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:])) //nolint:errcheck
+
+			for i := 0; i < n; i++ {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				size, _ := strconv.Atoi(strings.TrimSpace(line[1:])) //nolint:errcheck
+				arg := make([]byte, size+2)
+
+				if _, err := io.ReadFull(r, arg); err != nil {
+					return
+				}
+
+				if i == 0 {
+					cmds <- string(arg[:size])
+				}
+			}
+
+			if _, err := c.Write([]byte(reply)); err != nil {
+				return
+			}
+		}
+	}()
+
+	return l.Addr().String(), cmds
+}
+
+func TestRedisRateLimitStore_IncrementWindow_nestedErrorReply(t *testing.T) {
+	addr, _ := runRESPServer(t,
+		"*2\r\n-ERR boom\r\n:1\r\n",
+		"*2\r\n:1\r\n:1\r\n",
+	)
+
+	store := servers.NewRedisRateLimitStore(servers.RedisRateLimitStoreConfig{Addr: addr, Timeout: time.Second})
+
+	defer store.Close() //nolint:errcheck
+
+	ctx := context.Background()
+
+	_, _, err := store.IncrementWindow(ctx, "w1", "w0", 0, 2, time.Second)
+	require.ErrorIs(t, err, servers.ErrRedis)
+	assert.Contains(t, err.Error(), "boom")
+
+	// The pooled connection is reused, the whole reply of the previous call was read.
+	count, allowed, err := store.IncrementWindow(ctx, "w1", "w0", 0, 2, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.True(t, allowed)
+}
+
+func TestRedisRateLimitStore_IncrementWindow_scriptCache(t *testing.T) {
+	addr, cmds := runRESPServer(t,
+		"-NOSCRIPT No matching script. Please use EVAL.\r\n",
+		"*2\r\n:1\r\n:1\r\n",
+		"*2\r\n:2\r\n:1\r\n",
+	)
+
+	store := servers.NewRedisRateLimitStore(servers.RedisRateLimitStoreConfig{Addr: addr, Timeout: time.Second})
+
+	defer store.Close() //nolint:errcheck
+
+	ctx := context.Background()
+
+	// The script is sent once, when the server does not have it cached.
+	count, allowed, err := store.IncrementWindow(ctx, "w1", "w0", 0, 2, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.True(t, allowed)
+
+	count, allowed, err = store.IncrementWindow(ctx, "w1", "w0", 0, 2, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.True(t, allowed)
+
+	assert.Equal(t, "EVALSHA", <-cmds)
+	assert.Equal(t, "EVAL", <-cmds)
+	assert.Equal(t, "EVALSHA", <-cmds)
+}
@@ -0,0 +1,358 @@
+package servers
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" //nolint:gosec // Redis identifies the scripts by their SHA1.
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRedis is returned when the Redis server replies with an error or an unexpected reply.
+var ErrRedis = errors.New("redis")
+
+// RedisRateLimitStoreConfig contains configuration options for a RedisRateLimitStore.
+type RedisRateLimitStoreConfig struct {
+	Addr     string        `envconfig:"ADDR" required:"true"`
+	Username string        `envconfig:"USERNAME"`
+	Password string        `envconfig:"PASSWORD"`
+	DB       int           `envconfig:"DB"`
+	PoolSize int           `envconfig:"POOL_SIZE" default:"10"`
+	Timeout  time.Duration `envconfig:"TIMEOUT" default:"100ms"`
+}
+
+// RedisRateLimitStore is a RateLimitStore speaking the Redis protocol (RESP), so it works with Redis and any
+// compatible server supporting Lua scripts. It keeps a pool of connections, each call runs a script checking and
+// incrementing the counters atomically in a single round trip.
+type RedisRateLimitStore struct {
+	config RedisRateLimitStoreConfig
+	pool   chan *redisConn
+}
+
+// NewRedisRateLimitStore creates a new RedisRateLimitStore. Connections are opened on demand.
+func NewRedisRateLimitStore(config RedisRateLimitStoreConfig) *RedisRateLimitStore {
+	if config.PoolSize <= 0 {
+		config.PoolSize = 10
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = 100 * time.Millisecond
+	}
+
+	return &RedisRateLimitStore{
+		config: config,
+		pool:   make(chan *redisConn, config.PoolSize),
+	}
+}
+
+// redisIncrementWindowScript counts the call in the current window when the sliding count is within the limit.
+// KEYS are the current and previous window keys, ARGV the previous window weight, the limit and the ttl in
+// milliseconds. It replies the sliding count and 1 when the call is allowed, 0 otherwise.
+const redisIncrementWindowScript = `
+local curr = tonumber(redis.call('GET', KEYS[1]) or '0')
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+local count = math.ceil(prev * tonumber(ARGV[1]) + curr + 1)
+
+if count > tonumber(ARGV[2]) then
+	return {count - 1, 0}
+end
+
+redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+
+return {count, 1}
+`
+
+// redisIncrementWindowSHA is the SHA1 digest of redisIncrementWindowScript, used to run it with EVALSHA.
+var redisIncrementWindowSHA = func() string {
+	sum := sha1.Sum([]byte(redisIncrementWindowScript)) //nolint:gosec
+
+	return hex.EncodeToString(sum[:])
+}()
+
+// IncrementWindow implements RateLimitStore. The script is run by its digest with EVALSHA and sent with EVAL
+// only when the server does not have it cached yet, which also caches it.
+func (s *RedisRateLimitStore) IncrementWindow(
+	ctx context.Context,
+	key, prevKey string,
+	prevWeight float64,
+	limit int64,
+	ttl time.Duration,
+) (int64, bool, error) {
+	c, err := s.conn(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+
+	args := []string{
+		"2", key, prevKey,
+		strconv.FormatFloat(prevWeight, 'f', -1, 64),
+		strconv.FormatInt(limit, 10),
+		strconv.FormatInt(ttl.Milliseconds(), 10),
+	}
+
+	replies, err := c.do(ctx, s.config.Timeout, append([]string{"EVALSHA", redisIncrementWindowSHA}, args...))
+
+	var rerr redisError
+	if errors.As(err, &rerr) && strings.HasPrefix(string(rerr), "NOSCRIPT") {
+		replies, err = c.do(ctx, s.config.Timeout, append([]string{"EVAL", redisIncrementWindowScript}, args...))
+	}
+
+	if err != nil {
+		if !errors.As(err, &rerr) {
+			// The connection state is unknown, it is not returned to the pool.
+			_ = c.Close() //nolint:errcheck
+
+			return 0, false, err
+		}
+	}
+
+	s.release(c)
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	values, ok := replies[0].([]any)
+	if !ok || len(values) != 2 {
+		return 0, false, fmt.Errorf("%w: unexpected EVAL reply %v", ErrRedis, replies[0])
+	}
+
+	count, ok := values[0].(int64)
+	allowed, ok2 := values[1].(int64)
+
+	if !ok || !ok2 {
+		return 0, false, fmt.Errorf("%w: unexpected EVAL reply %v", ErrRedis, replies[0])
+	}
+
+	return count, allowed == 1, nil
+}
+
+// Close closes the pooled connections.
+func (s *RedisRateLimitStore) Close() error {
+	for {
+		select {
+		case c := <-s.pool:
+			_ = c.Close() //nolint:errcheck
+		default:
+			return nil
+		}
+	}
+}
+
+func (s *RedisRateLimitStore) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-s.pool:
+		return c, nil
+	default:
+	}
+
+	d := net.Dialer{Timeout: s.config.Timeout}
+
+	nc, err := d.DialContext(ctx, "tcp", s.config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: dial: %v", ErrRedis, err) //nolint:errorlint
+	}
+
+	c := &redisConn{
+		Conn: nc,
+		r:    bufio.NewReader(nc),
+	}
+
+	var cmds [][]string
+
+	if s.config.Password != "" {
+		if s.config.Username != "" {
+			cmds = append(cmds, []string{"AUTH", s.config.Username, s.config.Password})
+		} else {
+			cmds = append(cmds, []string{"AUTH", s.config.Password})
+		}
+	}
+
+	if s.config.DB != 0 {
+		cmds = append(cmds, []string{"SELECT", strconv.Itoa(s.config.DB)})
+	}
+
+	if len(cmds) > 0 {
+		if _, err := c.do(ctx, s.config.Timeout, cmds...); err != nil {
+			_ = c.Close() //nolint:errcheck
+
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (s *RedisRateLimitStore) release(c *redisConn) {
+	select {
+	case s.pool <- c:
+	default:
+		_ = c.Close() //nolint:errcheck
+	}
+}
+
+// redisConn is a connection to a Redis server.
+type redisConn struct {
+	net.Conn
+
+	r *bufio.Reader
+}
+
+// do pipelines the commands, returning their replies. A reply is nil, an int64, a string or a []any.
+func (c *redisConn) do(ctx context.Context, timeout time.Duration, cmds ...[]string) ([]any, error) {
+	deadline := time.Now().Add(timeout)
+
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRedis, err) //nolint:errorlint
+	}
+
+	var buf []byte
+
+	for _, cmd := range cmds {
+		buf = append(buf, '*')
+		buf = strconv.AppendInt(buf, int64(len(cmd)), 10)
+		buf = append(buf, '\r', '\n')
+
+		for _, arg := range cmd {
+			buf = append(buf, '$')
+			buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+			buf = append(buf, '\r', '\n')
+			buf = append(buf, arg...)
+			buf = append(buf, '\r', '\n')
+		}
+	}
+
+	if _, err := c.Write(buf); err != nil {
+		return nil, fmt.Errorf("%w: write: %v", ErrRedis, err) //nolint:errorlint
+	}
+
+	replies := make([]any, 0, len(cmds))
+
+	var replyErr error
+
+	for range cmds {
+		reply, err := readRESP(c.r)
+		if err != nil {
+			var rerr redisError
+			if !errors.As(err, &rerr) {
+				return nil, err
+			}
+
+			// Keep reading the rest of the replies so the connection stays usable.
+			replyErr = err
+		}
+
+		replies = append(replies, reply)
+	}
+
+	if replyErr != nil {
+		return nil, replyErr
+	}
+
+	return replies, nil
+}
+
+// redisError is an error reply of the Redis server.
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+func (e redisError) Unwrap() error {
+	return ErrRedis
+}
+
+// readRESP reads a RESP reply.
+func readRESP(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("%w: read: %v", ErrRedis, err) //nolint:errorlint
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: malformed reply %q", ErrRedis, line)
+	}
+
+	payload := line[1 : len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		n, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed integer %q", ErrRedis, payload)
+		}
+
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed bulk length %q", ErrRedis, payload)
+		}
+
+		if n < 0 {
+			return nil, nil //nolint:nilnil
+		}
+
+		data := make([]byte, n+2)
+
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("%w: read: %v", ErrRedis, err) //nolint:errorlint
+		}
+
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed array length %q", ErrRedis, payload)
+		}
+
+		if n < 0 {
+			return nil, nil //nolint:nilnil
+		}
+
+		arr := make([]any, 0, n)
+
+		var elemErr error
+
+		for i := 0; i < n; i++ {
+			v, err := readRESP(r)
+			if err != nil {
+				var rerr redisError
+				if !errors.As(err, &rerr) {
+					return nil, err
+				}
+
+				// Keep reading the rest of the elements so the connection stays usable.
+				if elemErr == nil {
+					elemErr = err
+				}
+			}
+
+			arr = append(arr, v)
+		}
+
+		if elemErr != nil {
+			return nil, elemErr
+		}
+
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown reply type %q", ErrRedis, line[0])
+	}
+}
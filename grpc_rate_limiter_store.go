@@ -0,0 +1,243 @@
+package servers
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// Rate limiter store defaults.
+const (
+	defaultRateLimiterShards     = 32
+	defaultRateLimiterMaxClients = 100_000
+	defaultRateLimiterIdleTTL    = 10 * time.Minute
+)
+
+// Eviction reasons.
+const (
+	evictionIdle     = "idle"
+	evictionCapacity = "capacity"
+)
+
+// WithIdleTTL sets the time a client bucket is kept without being used. An evicted bucket is recreated full.
+// Zero disables idle eviction. Defaults to 10 minutes.
+func WithIdleTTL(ttl time.Duration) RateLimiterOption {
+	return func(r *PerClientRateLimiter) {
+		r.store.idleTTL = ttl
+	}
+}
+
+// WithMaxClients sets the maximum number of client buckets tracked, evicting the least recently used ones
+// above it. Zero means no limit. Defaults to 100000.
+//
+// The bound applies to all the shards, see WithShards. Tracking a new client above it evicts the least recently
+// used bucket of the client shard, or of another shard when the new bucket is the only one in its shard. The other
+// shards are skipped while locked, so the bound may be briefly exceeded under contention.
+func WithMaxClients(n int) RateLimiterOption {
+	return func(r *PerClientRateLimiter) {
+		r.store.maxClients = n
+	}
+}
+
+// WithShards sets the number of shards the client buckets are split in, each one with its own lock.
+// Defaults to 32.
+func WithShards(n int) RateLimiterOption {
+	return func(r *PerClientRateLimiter) {
+		if n > 0 {
+			r.store.shardsCount = n
+		}
+	}
+}
+
+// WithRateLimiterName sets the name of the limiter, exported as the "limiter" label of its metrics. The metrics
+// of the limiters registered in the same prometheus.Registerer must be told apart by their names.
+func WithRateLimiterName(name string) RateLimiterOption {
+	return func(r *PerClientRateLimiter) {
+		r.store.name = name
+	}
+}
+
+// bucketStore keeps the client buckets in sharded LRU lists, evicting idle and least recently used buckets.
+type bucketStore struct {
+	name        string
+	shardsCount int
+	maxClients  int
+	idleTTL     time.Duration
+
+	shards []*bucketShard
+	now    func() time.Time
+
+	tracked   atomic.Int64
+	evictions *prometheus.CounterVec
+	clients   prometheus.GaugeFunc
+}
+
+type bucketShard struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type bucketEntry struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newBucketStore() *bucketStore {
+	return &bucketStore{
+		shardsCount: defaultRateLimiterShards,
+		maxClients:  defaultRateLimiterMaxClients,
+		idleTTL:     defaultRateLimiterIdleTTL,
+		now:         time.Now,
+	}
+}
+
+// init creates the shards and the metrics, once the options are applied.
+func (s *bucketStore) init() {
+	var labels prometheus.Labels
+
+	if s.name != "" {
+		labels = prometheus.Labels{"limiter": s.name}
+	}
+
+	s.evictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "grpc_rate_limiter_evictions_total",
+		Help:        "Total number of client buckets evicted by the rate limiter.",
+		ConstLabels: labels,
+	}, []string{"reason"})
+
+	s.clients = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "grpc_rate_limiter_tracked_clients",
+		Help:        "Number of client buckets tracked by the rate limiter.",
+		ConstLabels: labels,
+	}, func() float64 {
+		return float64(s.tracked.Load())
+	})
+
+	s.shards = make([]*bucketShard, s.shardsCount)
+
+	for i := range s.shards {
+		s.shards[i] = &bucketShard{
+			entries: make(map[string]*list.Element),
+			lru:     list.New(),
+		}
+	}
+}
+
+func (s *bucketStore) shard(key string) *bucketShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key)) //nolint:errcheck
+
+	return s.shards[h.Sum32()%uint32(len(s.shards))] //nolint:gosec
+}
+
+// get returns the bucket of the key, creating it with the limits when missing, or updating its limits when they
+// changed.
+func (s *bucketStore) get(key string, rps float64, burst int) *rate.Limiter {
+	sh := s.shard(key)
+	now := s.now()
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	s.evictIdle(sh, now)
+
+	if el, ok := sh.entries[key]; ok {
+		e := el.Value.(*bucketEntry) //nolint:forcetypeassert
+		e.lastSeen = now
+
+		sh.lru.MoveToFront(el)
+
+		if e.limiter.Limit() != rate.Limit(rps) {
+			e.limiter.SetLimit(rate.Limit(rps))
+		}
+
+		if e.limiter.Burst() != burst {
+			e.limiter.SetBurst(burst)
+		}
+
+		return e.limiter
+	}
+
+	e := &bucketEntry{
+		key:      key,
+		limiter:  rate.NewLimiter(rate.Limit(rps), burst),
+		lastSeen: now,
+	}
+
+	sh.entries[key] = sh.lru.PushFront(e)
+
+	tracked := s.tracked.Add(1)
+
+	if s.maxClients > 0 && tracked > int64(s.maxClients) {
+		s.evictCapacity(sh)
+	}
+
+	return e.limiter
+}
+
+// evictCapacity removes the least recently used buckets above maxClients, from the locked shard sh first, keeping
+// the bucket just created in it, then from the other shards not locked.
+func (s *bucketStore) evictCapacity(sh *bucketShard) {
+	for s.tracked.Load() > int64(s.maxClients) && sh.lru.Len() > 1 {
+		s.remove(sh, sh.lru.Back(), evictionCapacity)
+	}
+
+	for _, other := range s.shards {
+		if s.tracked.Load() <= int64(s.maxClients) {
+			return
+		}
+
+		// Waiting for another shard while holding sh could deadlock.
+		if other == sh || !other.mu.TryLock() {
+			continue
+		}
+
+		for s.tracked.Load() > int64(s.maxClients) && other.lru.Len() > 0 {
+			s.remove(other, other.lru.Back(), evictionCapacity)
+		}
+
+		other.mu.Unlock()
+	}
+}
+
+// evictIdle removes the buckets not used within the idle TTL, starting from the least recently used.
+func (s *bucketStore) evictIdle(sh *bucketShard, now time.Time) {
+	if s.idleTTL <= 0 {
+		return
+	}
+
+	for el := sh.lru.Back(); el != nil; el = sh.lru.Back() {
+		if now.Sub(el.Value.(*bucketEntry).lastSeen) < s.idleTTL { //nolint:forcetypeassert
+			return
+		}
+
+		s.remove(sh, el, evictionIdle)
+	}
+}
+
+func (s *bucketStore) remove(sh *bucketShard, el *list.Element, reason string) {
+	sh.lru.Remove(el)
+	delete(sh.entries, el.Value.(*bucketEntry).key) //nolint:forcetypeassert
+
+	s.tracked.Add(-1)
+	s.evictions.WithLabelValues(reason).Inc()
+}
+
+// Describe implements prometheus.Collector.
+func (s *bucketStore) Describe(ch chan<- *prometheus.Desc) {
+	s.clients.Describe(ch)
+	s.evictions.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (s *bucketStore) Collect(ch chan<- prometheus.Metric) {
+	s.clients.Collect(ch)
+	s.evictions.Collect(ch)
+}
@@ -0,0 +1,293 @@
+package servers_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/testdata"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func dialGRPC(t *testing.T, addr string) *grpc.ClientConn {
+	t.Helper()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "grpc connection: %v", err)
+
+	t.Cleanup(func() {
+		conn.Close() //nolint:errcheck,gosec
+	})
+
+	return conn
+}
+
+func TestPerClientRateLimiter_Unary(t *testing.T) {
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(servers.NewPerClientRateLimiter(0.001, 1)))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewGreeterClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	require.NoError(t, err)
+
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestPerClientRateLimiter_Stream(t *testing.T) {
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(servers.NewPerClientRateLimiter(0.001, 1)))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewStreamGreeterClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := c.SayHellos(ctx, &testdata.HelloRequest{Name: "a,b"})
+	require.NoError(t, err)
+
+	r, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "Hello a", r.GetMessage())
+
+	stream, err = c.SayHellos(ctx, &testdata.HelloRequest{Name: "a,b"})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestPerClientRateLimiter_StreamMessages(t *testing.T) {
+	limiter := servers.NewPerClientRateLimiter(10, 10, servers.WithStreamMessageRate(0.001, 2))
+
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(limiter))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewStreamGreeterClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := c.Chat(ctx)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, stream.Send(&testdata.HelloRequest{Name: "test"}))
+
+		_, err = stream.Recv()
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, stream.Send(&testdata.HelloRequest{Name: "test"}))
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestPerClientRateLimiter_Rules(t *testing.T) {
+	rules, err := servers.ParseRateLimitRules([]byte(`[
+		{"name": "premium", "methods": ["/helloworld.Greeter/*"], "tiers": ["premium"], "rps": 0.001, "burst": 3},
+		{"name": "greeter", "methods": ["/helloworld.Greeter/*"], "rps": 0.001, "burst": 1}
+	]`))
+	require.NoError(t, err)
+
+	limiter := servers.NewPerClientRateLimiter(10, 10, servers.WithRules(rules...))
+
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(limiter))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewGreeterClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	sayHello := func(tier string) error {
+		ctx := metadata.AppendToOutgoingContext(ctx, "client-id", "test-client", "client-tier", tier)
+
+		_, err := c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+
+		return err
+	}
+
+	require.NoError(t, sayHello("free"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(sayHello("free")))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, sayHello("premium"))
+	}
+
+	assert.Equal(t, codes.ResourceExhausted, status.Code(sayHello("premium")))
+
+	// The unidentified calls share the bucket of the rule, rather than the default limits.
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	require.NoError(t, err)
+
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Replacing the rules at runtime.
+	require.NoError(t, limiter.SetRules([]servers.RateLimitRule{
+		{Name: "greeter", Methods: []string{"/helloworld.Greeter/*"}, RPS: 0.001, Burst: 2},
+	}))
+
+	assert.Len(t, limiter.Rules(), 1)
+
+	// The bucket keeps its state, adopting the new burst.
+	assert.Equal(t, codes.ResourceExhausted, status.Code(sayHello("free")))
+
+	require.Error(t, limiter.SetRules([]servers.RateLimitRule{{Name: "invalid", RPS: -1}}))
+}
+
+func TestPerClientRateLimiter_Eviction(t *testing.T) {
+	limiter := servers.NewPerClientRateLimiter(0.001, 1, servers.WithMaxClients(1), servers.WithRateLimiterName("eviction"))
+
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(limiter))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewGreeterClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	sayHello := func(clientID string) error {
+		_, err := c.SayHello(metadata.AppendToOutgoingContext(ctx, "client-id", clientID), &testdata.HelloRequest{Name: "test"})
+
+		return err
+	}
+
+	require.NoError(t, sayHello("a"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(sayHello("a")))
+
+	// Tracking client b evicts the bucket of client a, which is recreated full.
+	require.NoError(t, sayHello("b"))
+	require.NoError(t, sayHello("a"))
+
+	// The limiters are told apart by their names.
+	reg := prom.NewRegistry()
+	reg.MustRegister(limiter, servers.NewPerClientRateLimiter(1, 1, servers.WithRateLimiterName("other")))
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := make(map[string]float64)
+
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			values[mf.GetName()] += m.GetGauge().GetValue() + m.GetCounter().GetValue()
+		}
+	}
+
+	assert.Equal(t, float64(1), values["grpc_rate_limiter_tracked_clients"])
+	assert.Equal(t, float64(2), values["grpc_rate_limiter_evictions_total"])
+}
+
+func TestPerClientRateLimiter_MaxClients(t *testing.T) {
+	limiter := servers.NewPerClientRateLimiter(1, 1, servers.WithMaxClients(3))
+
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(limiter))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewGreeterClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The clients are spread over the shards, the bound applies to all of them.
+	for i := range 20 {
+		_, err := c.SayHello(metadata.AppendToOutgoingContext(ctx, "client-id", fmt.Sprintf("client-%d", i)), &testdata.HelloRequest{Name: "test"})
+		require.NoError(t, err)
+	}
+
+	reg := prom.NewRegistry()
+	reg.MustRegister(limiter)
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := make(map[string]float64)
+
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			values[mf.GetName()] += m.GetGauge().GetValue() + m.GetCounter().GetValue()
+		}
+	}
+
+	assert.Equal(t, float64(3), values["grpc_rate_limiter_tracked_clients"])
+	assert.Equal(t, float64(17), values["grpc_rate_limiter_evictions_total"])
+}
+
+func TestPerClientRateLimiter_Signalling(t *testing.T) {
+	srv, addr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(servers.NewPerClientRateLimiter(0.5, 2)))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewGreeterClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var header metadata.MD
+
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"}, grpc.Header(&header))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"2"}, header.Get("ratelimit-limit"))
+	assert.Equal(t, []string{"1"}, header.Get("ratelimit-remaining"))
+	assert.Equal(t, []string{"2"}, header.Get("ratelimit-reset"))
+
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	require.NoError(t, err)
+
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	require.Error(t, err)
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+
+	var (
+		retryInfo    *errdetails.RetryInfo
+		quotaFailure *errdetails.QuotaFailure
+	)
+
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.RetryInfo:
+			retryInfo = d
+		case *errdetails.QuotaFailure:
+			quotaFailure = d
+		}
+	}
+
+	require.NotNil(t, retryInfo)
+	assert.InDelta(t, 2*time.Second, retryInfo.GetRetryDelay().AsDuration(), float64(100*time.Millisecond))
+
+	require.NotNil(t, quotaFailure)
+	assert.Equal(t, "client:unknown-client", quotaFailure.GetViolations()[0].GetSubject())
+}
@@ -0,0 +1,487 @@
+package servers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrUnknownMethod is returned when replaying an interaction of a method that has no registered descriptor.
+var ErrUnknownMethod = errors.New("unknown method")
+
+// Interaction is a recorded gRPC call, the messages rendered as JSON.
+type Interaction struct {
+	FullMethod string              `json:"full_method"`
+	Metadata   map[string][]string `json:"metadata,omitempty"`
+	Requests   []json.RawMessage   `json:"requests,omitempty"`
+	Responses  []json.RawMessage   `json:"responses,omitempty"`
+	Code       string              `json:"code"`
+	Message    string              `json:"message,omitempty"`
+	Details    []json.RawMessage   `json:"details,omitempty"`
+}
+
+// RecorderConfig contains configuration options for a Recorder.
+type RecorderConfig struct {
+	// Path is the file the interactions are appended to, as JSON lines.
+	Path string `envconfig:"PATH" required:"true"`
+	// Methods are the full method glob patterns recorded, every method when empty.
+	Methods []string `envconfig:"METHODS"`
+	// Metadata are the incoming metadata keys recorded and replayed, e.g. "authorization". None by default.
+	Metadata []string `envconfig:"METADATA"`
+	// RedactFields are the field paths masked in the recorded messages, e.g. "user.password", as in
+	// PayloadLoggingRule. Fields marked with the debug_redact proto option are always masked, so the recordings
+	// replay the masked values.
+	RedactFields []string `envconfig:"REDACT_FIELDS"`
+}
+
+// Recorder records the gRPC calls served by a GRPC server to a file, see WithRecorder and Replay.
+type Recorder struct {
+	config RecorderConfig
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewRecorder creates a Recorder, opening (or creating) the file in append mode.
+func NewRecorder(config RecorderConfig) (*Recorder, error) {
+	f, err := os.OpenFile(config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open recording file: %w", err)
+	}
+
+	return &Recorder{config: config, f: f}, nil
+}
+
+// Close closes the underlying file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return nil
+	}
+
+	err := r.f.Close()
+	r.f = nil
+
+	return err
+}
+
+func (r *Recorder) records(fullMethod string) bool {
+	return len(r.config.Methods) == 0 || matchFullMethod(r.config.Methods, fullMethod)
+}
+
+// marshal renders the message as JSON with the redacted fields masked, nil if it is not a proto message.
+func (r *Recorder) marshal(payload any) []byte {
+	m, ok := payload.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	return marshalPayload(redactMessage(m, r.config.RedactFields))
+}
+
+func (r *Recorder) newInteraction(ctx context.Context, fullMethod string) *Interaction {
+	in := &Interaction{FullMethod: fullMethod}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, k := range r.config.Metadata {
+			if v := md.Get(k); len(v) > 0 {
+				if in.Metadata == nil {
+					in.Metadata = make(map[string][]string)
+				}
+
+				in.Metadata[strings.ToLower(k)] = v
+			}
+		}
+	}
+
+	return in
+}
+
+func (r *Recorder) record(in *Interaction, err error) {
+	st := status.Convert(err)
+
+	in.Code = st.Code().String()
+	in.Message = st.Message()
+
+	for _, d := range st.Proto().GetDetails() {
+		if data, err := protojson.Marshal(d); err == nil {
+			in.Details = append(in.Details, data)
+		}
+	}
+
+	line, err := json.Marshal(in)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f != nil {
+		_, _ = r.f.Write(append(line, '\n')) //nolint:errcheck
+	}
+}
+
+// UnaryServerInterceptor returns a new unary server interceptor that records the calls.
+func (r *Recorder) UnaryServerInterceptor() func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !r.records(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		in := r.newInteraction(ctx, info.FullMethod)
+
+		if data := r.marshal(req); data != nil {
+			in.Requests = append(in.Requests, data)
+		}
+
+		resp, err := handler(ctx, req)
+		if err == nil {
+			if data := r.marshal(resp); data != nil {
+				in.Responses = append(in.Responses, data)
+			}
+		}
+
+		r.record(in, err)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a new stream server interceptor that records the calls once the stream ends.
+func (r *Recorder) StreamServerInterceptor() func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !r.records(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		rs := &recordingServerStream{
+			ServerStream: ss,
+			recorder:     r,
+			in:           r.newInteraction(ss.Context(), info.FullMethod),
+		}
+
+		err := handler(srv, rs)
+
+		r.record(rs.in, err)
+
+		return err
+	}
+}
+
+// recordingServerStream records the messages of a stream.
+type recordingServerStream struct {
+	grpc.ServerStream
+
+	recorder *Recorder
+
+	mu sync.Mutex
+	in *Interaction
+}
+
+func (s *recordingServerStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		if data := s.recorder.marshal(m); data != nil {
+			s.mu.Lock()
+			s.in.Requests = append(s.in.Requests, data)
+			s.mu.Unlock()
+		}
+	}
+
+	return err
+}
+
+func (s *recordingServerStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		if data := s.recorder.marshal(m); data != nil {
+			s.mu.Lock()
+			s.in.Responses = append(s.in.Responses, data)
+			s.mu.Unlock()
+		}
+	}
+
+	return err
+}
+
+// WithRecorder sets the Recorder, used to append UnaryServerInterceptor and StreamServerInterceptor before
+// StageLogging, so the calls rejected by the later stages are recorded too.
+// Apply to GRPC server instances.
+func WithRecorder(recorder *Recorder) Option {
+	return WithInterceptorBefore(StageLogging, "recorder", recorder.UnaryServerInterceptor(), recorder.StreamServerInterceptor())
+}
+
+// LoadRecording reads the interactions recorded to the file.
+func LoadRecording(path string) ([]Interaction, error) {
+	f, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("open recording file: %w", err)
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	var interactions []Interaction
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+
+	for sc.Scan() {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+
+		var in Interaction
+
+		if err := json.Unmarshal(sc.Bytes(), &in); err != nil {
+			return nil, fmt.Errorf("decode interaction %d: %w", len(interactions)+1, err)
+		}
+
+		interactions = append(interactions, in)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read recording file: %w", err)
+	}
+
+	return interactions, nil
+}
+
+// ReplayConfig contains configuration options for Replay.
+type ReplayConfig struct {
+	// IgnoreFields are the JSON field names ignored at any depth of the responses and status details when
+	// comparing, e.g. "error_id" or "createdAt". "error_id" is always ignored.
+	IgnoreFields []string
+	// RedactFields are the field paths masked in the responses before comparing, as the RecorderConfig
+	// RedactFields of the recording. Fields marked with the debug_redact proto option are always masked.
+	RedactFields []string
+}
+
+// ReplayDiff is an interaction whose replay did not match the recording.
+type ReplayDiff struct {
+	// Index is the position of the interaction in the recording.
+	Index       int
+	Interaction Interaction
+	Got         Interaction
+	// Diffs are the differences, one per JSON path, e.g. `responses[0].message: want "Hello" got "Hi"`.
+	Diffs []string
+}
+
+// Replay replays the interactions against the connection, returning the ones whose responses or status differ
+// from the recording. It returns an error when an interaction can not be replayed.
+func Replay(ctx context.Context, conn grpc.ClientConnInterface, interactions []Interaction, config ReplayConfig) ([]ReplayDiff, error) {
+	ignore := append([]string{"error_id"}, config.IgnoreFields...)
+
+	var diffs []ReplayDiff
+
+	for i, in := range interactions {
+		got, err := replay(ctx, conn, in, config.RedactFields)
+		if err != nil {
+			return nil, fmt.Errorf("replay interaction %d %s: %w", i, in.FullMethod, err)
+		}
+
+		if d := diffInteractions(in, got, ignore); len(d) > 0 {
+			diffs = append(diffs, ReplayDiff{Index: i, Interaction: in, Got: got, Diffs: d})
+		}
+	}
+
+	return diffs, nil
+}
+
+// replay sends the interaction requests and records the responses, masking the redacted fields.
+func replay(ctx context.Context, conn grpc.ClientConnInterface, in Interaction, redactFields []string) (Interaction, error) {
+	md, ok := methodDescriptor(in.FullMethod)
+	if !ok {
+		return Interaction{}, fmt.Errorf("%w: %s", ErrUnknownMethod, in.FullMethod)
+	}
+
+	input, output := messageType(md.Input()), messageType(md.Output())
+
+	if len(in.Metadata) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.MD(in.Metadata))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	got := Interaction{FullMethod: in.FullMethod, Metadata: in.Metadata, Requests: in.Requests}
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, in.FullMethod)
+	if err != nil {
+		return Interaction{}, err
+	}
+
+	for _, data := range in.Requests {
+		req := input.New().Interface()
+
+		if err := protojson.Unmarshal(data, req); err != nil {
+			return Interaction{}, fmt.Errorf("decode request: %w", err)
+		}
+
+		if err := stream.SendMsg(req); err != nil {
+			// The status is received below.
+			break
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return Interaction{}, err
+	}
+
+	for {
+		resp := output.New().Interface()
+
+		err = stream.RecvMsg(resp)
+		if err != nil {
+			break
+		}
+
+		got.Responses = append(got.Responses, marshalPayload(redactMessage(resp, redactFields)))
+	}
+
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+
+	st := status.Convert(err)
+
+	got.Code = st.Code().String()
+	got.Message = st.Message()
+
+	for _, d := range st.Proto().GetDetails() {
+		if data, err := protojson.Marshal(d); err == nil {
+			got.Details = append(got.Details, data)
+		}
+	}
+
+	return got, nil
+}
+
+// diffInteractions compares the responses and status of the interactions, ignoring the fields.
+func diffInteractions(want, got Interaction, ignore []string) []string {
+	var diffs []string
+
+	if want.Code != got.Code {
+		diffs = append(diffs, fmt.Sprintf("code: want %s got %s", want.Code, got.Code))
+	}
+
+	if want.Message != got.Message {
+		diffs = append(diffs, fmt.Sprintf("message: want %q got %q", want.Message, got.Message))
+	}
+
+	diffs = append(diffs, diffJSON("responses", rawMessages(want.Responses), rawMessages(got.Responses), ignore)...)
+	diffs = append(diffs, diffJSON("details", rawMessages(want.Details), rawMessages(got.Details), ignore)...)
+
+	return diffs
+}
+
+// rawMessages decodes the messages into generic JSON values.
+func rawMessages(msgs []json.RawMessage) []any {
+	values := make([]any, 0, len(msgs))
+
+	for _, m := range msgs {
+		var v any
+
+		_ = json.Unmarshal(m, &v) //nolint:errcheck
+
+		values = append(values, v)
+	}
+
+	return values
+}
+
+// diffJSON returns the differences between the JSON values, one per path, ignoring the object fields.
+func diffJSON(path string, want, got any, ignore []string) []string {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(w)+len(g))
+
+		for k := range w {
+			keys = append(keys, k)
+		}
+
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+
+		var diffs []string
+
+		for _, k := range keys {
+			if slices.Contains(ignore, k) {
+				continue
+			}
+
+			diffs = append(diffs, diffJSON(path+"."+k, w[k], g[k], ignore)...)
+		}
+
+		return diffs
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			break
+		}
+
+		var diffs []string
+
+		for i := range max(len(w), len(g)) {
+			var wv, gv any
+
+			if i < len(w) {
+				wv = w[i]
+			}
+
+			if i < len(g) {
+				gv = g[i]
+			}
+
+			diffs = append(diffs, diffJSON(fmt.Sprintf("%s[%d]", path, i), wv, gv, ignore)...)
+		}
+
+		return diffs
+	}
+
+	if reflect.DeepEqual(want, got) {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%s: want %s got %s", path, jsonString(want), jsonString(got))}
+}
+
+func jsonString(v any) string {
+	if v == nil {
+		return "<missing>"
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(data)
+}
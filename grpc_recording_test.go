@@ -0,0 +1,164 @@
+package servers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRecorder_Replay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+
+	recorder, err := servers.NewRecorder(servers.RecorderConfig{
+		Path:     path,
+		Metadata: []string{"x-tenant"},
+	})
+	require.NoError(t, err)
+
+	deny := func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if r, ok := req.(*testdata.HelloRequest); ok && r.GetName() == "denied" {
+			return nil, servers.Error(codes.PermissionDenied, "not allowed", map[string]string{"name": r.GetName()})
+		}
+
+		return handler(ctx, req)
+	}
+
+	grpcSrv, grpcAddr, err := startGRPCService(nil, nil, nil,
+		servers.WithRecorder(recorder),
+		servers.WithStageInterceptor(servers.StageAuth, "deny", deny, nil),
+	)
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer grpcSrv.Stop()
+
+	conn := dialGRPC(t, grpcAddr)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", "acme", "authorization", "secret")
+
+	greeter := testdata.NewGreeterClient(conn)
+
+	_, err = greeter.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	require.NoError(t, err)
+
+	_, err = greeter.SayHello(ctx, &testdata.HelloRequest{Name: "denied"})
+	require.Error(t, err)
+
+	streamGreeter := testdata.NewStreamGreeterClient(conn)
+
+	stream, err := streamGreeter.SayHellos(ctx, &testdata.HelloRequest{Name: "a,b"})
+	require.NoError(t, err)
+
+	for {
+		if _, err := stream.Recv(); err != nil {
+			require.ErrorIs(t, err, io.EOF)
+
+			break
+		}
+	}
+
+	chat, err := streamGreeter.Chat(ctx)
+	require.NoError(t, err)
+	require.NoError(t, chat.Send(&testdata.HelloRequest{Name: "c"}))
+	require.NoError(t, chat.CloseSend())
+
+	for {
+		if _, err := chat.Recv(); err != nil {
+			require.True(t, errors.Is(err, io.EOF))
+
+			break
+		}
+	}
+
+	require.NoError(t, recorder.Close())
+
+	interactions, err := servers.LoadRecording(path)
+	require.NoError(t, err)
+	require.Len(t, interactions, 4)
+
+	assert.Equal(t, testdata.Greeter_SayHello_FullMethodName, interactions[0].FullMethod)
+	assert.Equal(t, map[string][]string{"x-tenant": {"acme"}}, interactions[0].Metadata)
+	assert.Equal(t, "OK", interactions[0].Code)
+	assert.JSONEq(t, `{"message":"Hello test"}`, string(interactions[0].Responses[0]))
+
+	assert.Equal(t, "PermissionDenied", interactions[1].Code)
+	assert.Equal(t, "not allowed", interactions[1].Message)
+	require.Len(t, interactions[1].Details, 1)
+	assert.Contains(t, string(interactions[1].Details[0]), "error_id")
+
+	assert.Len(t, interactions[2].Responses, 2)
+	assert.Len(t, interactions[3].Requests, 1)
+
+	// Replaying against the same build matches, the error id changes but is ignored.
+	diffs, err := servers.Replay(context.Background(), conn, interactions, servers.ReplayConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	// A contract change is reported.
+	interactions[2].Responses[1] = json.RawMessage(`{"message":"Hi b"}`)
+	interactions[1].Code = "NotFound"
+
+	diffs, err = servers.Replay(context.Background(), conn, interactions, servers.ReplayConfig{})
+	require.NoError(t, err)
+	require.Len(t, diffs, 2)
+
+	assert.Equal(t, 1, diffs[0].Index)
+	assert.Equal(t, []string{"code: want NotFound got PermissionDenied"}, diffs[0].Diffs)
+	assert.Equal(t, 2, diffs[1].Index)
+	assert.Equal(t, []string{`responses[1].message: want "Hi b" got "Hello b"`}, diffs[1].Diffs)
+
+	// Volatile fields are ignored.
+	interactions[1].Code = "PermissionDenied"
+	interactions[1].Details[0] = json.RawMessage(`{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"not allowed","metadata":{"name":"other"}}`)
+
+	diffs, err = servers.Replay(context.Background(), conn, interactions[:2], servers.ReplayConfig{IgnoreFields: []string{"name"}})
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+}
+
+func TestRecorder_redact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+
+	recorder, err := servers.NewRecorder(servers.RecorderConfig{
+		Path:         path,
+		RedactFields: []string{"message"},
+	})
+	require.NoError(t, err)
+
+	grpcSrv, grpcAddr, err := startGRPCService(nil, nil, nil, servers.WithRecorder(recorder))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer grpcSrv.Stop()
+
+	conn := dialGRPC(t, grpcAddr)
+
+	_, err = testdata.NewGreeterClient(conn).SayHello(context.Background(), &testdata.HelloRequest{Name: "test"})
+	require.NoError(t, err)
+
+	require.NoError(t, recorder.Close())
+
+	interactions, err := servers.LoadRecording(path)
+	require.NoError(t, err)
+	require.Len(t, interactions, 1)
+
+	assert.JSONEq(t, `{"name":"test"}`, string(interactions[0].Requests[0]))
+	assert.JSONEq(t, `{"message":"[REDACTED]"}`, string(interactions[0].Responses[0]))
+
+	// The replayed responses are masked alike.
+	diffs, err := servers.Replay(context.Background(), conn, interactions, servers.ReplayConfig{RedactFields: []string{"message"}})
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	diffs, err = servers.Replay(context.Background(), conn, interactions, servers.ReplayConfig{})
+	require.NoError(t, err)
+	assert.Len(t, diffs, 1)
+}
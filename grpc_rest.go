@@ -14,6 +14,8 @@ type grpcRestOptions struct {
 
 	muxOpts  []runtime.ServeMuxOption
 	register []func(mux *runtime.ServeMux) error
+
+	middlewares []func(http.Handler) http.Handler
 }
 
 // WithServerMuxOption sets the options for the mux server.
@@ -144,6 +146,9 @@ func NewGRPCRest(config Config, opts ...Option) (*GRPCRest, error) {
 	// Use custom error handler but can be modified by opts from consumers.
 	WithServerMuxOption(runtime.WithErrorHandler(customizeErrorHandler()))(srv)
 
+	// Expose the path templates as the route patterns, e.g. for the access logs.
+	WithServerMuxOption(runtime.WithMiddlewares(gatewayRouteMiddleware))(srv)
+
 	for _, o := range opts {
 		o(srv)
 	}
@@ -163,6 +168,7 @@ func NewGRPCRest(config Config, opts ...Option) (*GRPCRest, error) {
 	})(srv)
 
 	WithResponseModifier(
+		rateLimitResponseModifier(),
 		xhttpCodeResponseModifier(),
 	)(srv)
 
@@ -176,7 +182,13 @@ func NewGRPCRest(config Config, opts ...Option) (*GRPCRest, error) {
 		}
 	}
 
-	srv.REST = NewREST(config, mux, opts...)
+	var handler http.Handler = mux
+
+	for i := len(srv.options.middlewares) - 1; i >= 0; i-- {
+		handler = srv.options.middlewares[i](handler)
+	}
+
+	srv.REST = NewREST(config, handler, opts...)
 
 	return srv, nil
 }
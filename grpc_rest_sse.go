@@ -0,0 +1,273 @@
+package servers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// sseContentType is the content type of Server-Sent Events.
+const sseContentType = "text/event-stream"
+
+// defaultSSEHeartbeatInterval is the interval of the heartbeat comments when not configured.
+const defaultSSEHeartbeatInterval = 15 * time.Second
+
+// SSEConfig contains configuration options for serving the server-streaming RPCs as Server-Sent Events.
+type SSEConfig struct {
+	// HeartbeatInterval is the interval of the heartbeat comments sent to keep the connection open, 15s by default.
+	// Negative disables the heartbeats.
+	HeartbeatInterval time.Duration `envconfig:"HEARTBEAT_INTERVAL" default:"15s"`
+	// Retry is the reconnection time advised to the clients, not sent when zero.
+	Retry time.Duration `envconfig:"RETRY"`
+}
+
+// WithSSE serves the server-streaming RPCs as Server-Sent Events to the clients accepting text/event-stream,
+// instead of newline delimited JSON. Each message is an event with an incrementing id and the message as data.
+// An error ends the stream with an "error" event carrying the error response.
+//
+// The Last-Event-ID sent by reconnecting clients is forwarded to the service as the last-event-id metadata, so it
+// can resume the stream, and the event ids continue from it when it is a number.
+// Apply to GRPCRest server instances.
+func WithSSE(config SSEConfig) Option {
+	if config.HeartbeatInterval == 0 {
+		config.HeartbeatInterval = defaultSSEHeartbeatInterval
+	}
+
+	return func(srv any) {
+		s, ok := srv.(*GRPCRest)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		s.options.middlewares = append(s.options.middlewares, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Accept"), sseContentType) {
+					next.ServeHTTP(w, r)
+
+					return
+				}
+
+				sw := &sseResponseWriter{
+					ResponseWriter: w,
+					config:         config,
+					stop:           make(chan struct{}),
+				}
+
+				if id := r.Header.Get("Last-Event-ID"); id != "" {
+					r.Header.Set(runtime.MetadataHeaderPrefix+"Last-Event-Id", id)
+
+					if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+						sw.id = n
+					}
+				}
+
+				defer sw.finish()
+
+				next.ServeHTTP(sw, r)
+			})
+		})
+	}
+}
+
+// sseResponseWriter translates the newline delimited JSON of the gateway streams to events. Other responses are
+// written as they are.
+type sseResponseWriter struct {
+	http.ResponseWriter
+
+	config SSEConfig
+
+	mu      sync.Mutex
+	decided bool
+	stream  bool
+	buf     []byte
+	id      uint64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// decide tells the gateway streams, which set Transfer-Encoding, apart before the header is written.
+func (sw *sseResponseWriter) decide() {
+	if sw.decided {
+		return
+	}
+
+	sw.decided = true
+
+	h := sw.ResponseWriter.Header()
+
+	if h.Get("Transfer-Encoding") != "chunked" {
+		return
+	}
+
+	sw.stream = true
+
+	h.Del("Transfer-Encoding")
+	h.Del("Content-Length")
+	h.Set("Content-Type", sseContentType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+
+	// Errors are sent as events, the stream is always successful for the client.
+	sw.ResponseWriter.WriteHeader(http.StatusOK)
+
+	if sw.config.Retry > 0 {
+		_, _ = fmt.Fprintf(sw.ResponseWriter, "retry: %d\n\n", sw.config.Retry.Milliseconds()) //nolint:errcheck
+	}
+
+	if sw.config.HeartbeatInterval > 0 {
+		sw.done = make(chan struct{})
+
+		go sw.heartbeat()
+	}
+}
+
+func (sw *sseResponseWriter) WriteHeader(code int) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	sw.decide()
+
+	if !sw.stream {
+		sw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (sw *sseResponseWriter) Write(b []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	sw.decide()
+
+	if !sw.stream {
+		return sw.ResponseWriter.Write(b)
+	}
+
+	sw.buf = append(sw.buf, b...)
+
+	for {
+		i := bytes.IndexByte(sw.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := bytes.TrimSpace(sw.buf[:i])
+		sw.buf = sw.buf[i+1:]
+
+		if len(line) == 0 {
+			continue
+		}
+
+		if err := sw.writeEvent(line); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// writeEvent writes the event of a gateway stream chunk, either {"result": message} or {"error": status}.
+func (sw *sseResponseWriter) writeEvent(chunk []byte) error {
+	var c struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+
+	if err := json.Unmarshal(chunk, &c); err != nil {
+		c.Result = chunk
+	}
+
+	if c.Error != nil {
+		var p spb.Status
+
+		st := status.New(codes.Internal, "malformed stream error")
+
+		if err := protojson.Unmarshal(c.Error, &p); err == nil {
+			st = status.FromProto(&p)
+		}
+
+		data, err := json.Marshal(newErrorResponse(st, runtime.HTTPStatusFromCode(st.Code())))
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(sw.ResponseWriter, "event: error\ndata: %s\n\n", data)
+
+		return err
+	}
+
+	sw.id++
+
+	var data bytes.Buffer
+
+	if err := json.Compact(&data, c.Result); err != nil {
+		data.Write(c.Result)
+	}
+
+	_, err := fmt.Fprintf(sw.ResponseWriter, "id: %d\ndata: %s\n\n", sw.id, data.Bytes())
+
+	return err
+}
+
+func (sw *sseResponseWriter) Flush() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	sw.decide()
+	sw.flush()
+}
+
+func (sw *sseResponseWriter) flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (sw *sseResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// heartbeat sends a comment every interval until the stream ends.
+func (sw *sseResponseWriter) heartbeat() {
+	defer close(sw.done)
+
+	ticker := time.NewTicker(sw.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sw.stop:
+			return
+		case <-ticker.C:
+			sw.mu.Lock()
+
+			if _, err := sw.ResponseWriter.Write([]byte(": heartbeat\n\n")); err == nil {
+				sw.flush()
+			}
+
+			sw.mu.Unlock()
+		}
+	}
+}
+
+// finish stops the heartbeats, so nothing is written once the handler returned.
+func (sw *sseResponseWriter) finish() {
+	close(sw.stop)
+
+	if sw.done != nil {
+		<-sw.done
+	}
+}
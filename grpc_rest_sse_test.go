@@ -0,0 +1,165 @@
+package servers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/serverstest"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type gRPCRestStreamTestServer struct{}
+
+// RegisterServiceHandlerConn registers the stream service implementation to mux on the connection.
+func (gRPCRestStreamTestServer) RegisterServiceHandlerConn(mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	return testdata.RegisterStreamGreeterHandler(context.Background(), mux, conn)
+}
+
+// sseEvent is a received Server-Sent Event, comments included.
+type sseEvent struct {
+	id, event, data string
+	comment         bool
+}
+
+// getSSE gets the events of the URL.
+func getSSE(t *testing.T, url string, header map[string]string) (*http.Response, []sseEvent) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer res.Body.Close() //nolint:errcheck
+
+	var (
+		events []sseEvent
+		e      sseEvent
+	)
+
+	sc := bufio.NewScanner(res.Body)
+
+	for sc.Scan() {
+		line := sc.Text()
+
+		switch {
+		case line == "":
+			events = append(events, e)
+			e = sseEvent{}
+		case strings.HasPrefix(line, ":"):
+			e.comment = true
+		default:
+			k, v, _ := strings.Cut(line, ": ")
+
+			switch k {
+			case "id":
+				e.id = v
+			case "event":
+				e.event = v
+			case "data":
+				e.data = v
+			}
+		}
+	}
+
+	require.NoError(t, sc.Err())
+
+	return res, events
+}
+
+func TestGRPCRest_WithSSE(t *testing.T) {
+	s := serverstest.Start(t,
+		serverstest.WithGRPC(servers.WithRegisterService(newGRPCTestServer(ctxd.NoOpLogger{}))),
+		serverstest.WithGateway(gRPCRestStreamTestServer{}, gRPCRestConnTestServer{}),
+		serverstest.WithGRPCRest(servers.WithSSE(servers.SSEConfig{HeartbeatInterval: 20 * time.Millisecond})),
+	)
+
+	t.Run("events", func(t *testing.T) {
+		res, events := getSSE(t, s.GRPCRestURL+"/hellos/a,b", nil)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+		assert.Equal(t, []sseEvent{
+			{id: "1", data: `{"message":"Hello a"}`},
+			{id: "2", data: `{"message":"Hello b"}`},
+		}, events)
+	})
+
+	t.Run("last event id", func(t *testing.T) {
+		_, events := getSSE(t, s.GRPCRestURL+"/hellos/c", map[string]string{"Last-Event-ID": "5"})
+
+		assert.Equal(t, []sseEvent{{id: "6", data: `{"message":"Hello c"}`}}, events)
+	})
+
+	t.Run("heartbeat", func(t *testing.T) {
+		_, events := getSSE(t, s.GRPCRestURL+"/hellos/a,wait,b", nil)
+
+		require.GreaterOrEqual(t, len(events), 3)
+		assert.Equal(t, sseEvent{id: "1", data: `{"message":"Hello a"}`}, events[0])
+		assert.True(t, events[1].comment)
+		assert.Equal(t, sseEvent{id: "2", data: `{"message":"Hello b"}`}, events[len(events)-1])
+	})
+
+	t.Run("error", func(t *testing.T) {
+		res, events := getSSE(t, s.GRPCRestURL+"/hellos/a,fail", nil)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		require.Len(t, events, 2)
+		assert.Equal(t, "error", events[1].event)
+
+		var e struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Error   string `json:"error"`
+			Details []struct {
+				Field       string `json:"field"`
+				Description string `json:"description"`
+			} `json:"details"`
+		}
+
+		require.NoError(t, json.Unmarshal([]byte(events[1].data), &e))
+		assert.Equal(t, http.StatusBadRequest, e.Code)
+		assert.Equal(t, "stream failed", e.Message)
+		assert.NotEmpty(t, e.Error)
+		require.Len(t, e.Details, 1)
+		assert.Equal(t, "name", e.Details[0].Field)
+
+		// Failing before the first message.
+		res, events = getSSE(t, s.GRPCRestURL+"/hellos/fail", nil)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		require.Len(t, events, 1)
+		assert.Equal(t, "error", events[0].event)
+	})
+
+	t.Run("unary", func(t *testing.T) {
+		res, _ := getSSE(t, s.GRPCRestURL+"/say/test", nil)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	})
+
+	t.Run("not accepted", func(t *testing.T) {
+		res := serverstest.Get(t, s.GRPCRestURL+"/hellos/a,b")
+
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	})
+}
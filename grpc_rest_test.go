@@ -213,3 +213,38 @@ func TestGRPCRest_StartServing_WithHandlers(t *testing.T) {
 
 	require.Equal(t, "Welcome to Test service", string(data))
 }
+
+func TestGRPCRest_RateLimitHeaders(t *testing.T) {
+	grpcSrv, grpcAddr, err := startGRPCService(nil, nil, nil, servers.WithGRPCRateLimiter(servers.NewPerClientRateLimiter(0.5, 1)))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer grpcSrv.Stop()
+
+	srv, addr, err := startGRPCRestService(grpcAddr, nil, nil)
+	require.NoErrorf(t, err, "start GRPC Rest: %v", err)
+
+	defer srv.Stop()
+
+	get := func() *http.Response {
+		res, err := http.Get(fmt.Sprintf("http://%s/say/test", addr))
+		require.NoError(t, err)
+
+		_, _ = io.Copy(io.Discard, res.Body) //nolint:errcheck
+		require.NoError(t, res.Body.Close())
+
+		return res
+	}
+
+	res := get()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "1", res.Header.Get("RateLimit-Limit"))
+	assert.Equal(t, "0", res.Header.Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", res.Header.Get("RateLimit-Reset"))
+	assert.Empty(t, res.Header.Get("Grpc-Metadata-Ratelimit-Limit"))
+
+	res = get()
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal(t, "2", res.Header.Get("Retry-After"))
+	assert.Equal(t, "1", res.Header.Get("RateLimit-Limit"))
+	assert.Equal(t, "0", res.Header.Get("RateLimit-Remaining"))
+}
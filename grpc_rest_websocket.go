@@ -0,0 +1,445 @@
+package servers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// WebSocket defaults.
+const (
+	defaultWebSocketPingInterval = 30 * time.Second
+	defaultWebSocketReadLimit    = 1 << 20
+
+	// webSocketStatusCloseCode is the close code of a non-OK status, plus the gRPC code.
+	webSocketStatusCloseCode = 4000
+	// webSocketMaxCloseReason is the maximum length of a close reason, for the close frame to fit a control frame.
+	webSocketMaxCloseReason = 123
+)
+
+// ErrInvalidWebSocketMessage is the error of a WebSocket message that is not a JSON request message.
+var ErrInvalidWebSocketMessage = errors.New("invalid websocket message")
+
+// webSocketForwardHeaders are the request headers always forwarded to the service as metadata.
+var webSocketForwardHeaders = []string{"Authorization", "X-Request-Id"}
+
+// WebSocketConfig contains configuration options for bridging WebSocket connections to streaming RPCs.
+type WebSocketConfig struct {
+	// AllowedOrigins are the origins allowed to connect, "*" allows any origin.
+	// Empty allows only same origin connections.
+	AllowedOrigins []string `envconfig:"ALLOWED_ORIGINS"`
+	// ForwardHeaders are request headers forwarded to the service as metadata on top of Authorization and
+	// X-Request-Id.
+	ForwardHeaders []string `envconfig:"FORWARD_HEADERS"`
+	// PingInterval is the interval of the ping frames sent to keep the connection open, 30s by default.
+	// Negative disables the pings.
+	PingInterval time.Duration `envconfig:"PING_INTERVAL" default:"30s"`
+	// ReadLimit is the maximum size in bytes of a client message, 1MiB by default.
+	ReadLimit int64 `envconfig:"READ_LIMIT" default:"1048576"`
+}
+
+// WithWebSocket bridges WebSocket connections to the streaming RPCs of the gateway, including client and
+// bidirectional streaming. The upgrade request path is the RPC HTTP path, and the RPC HTTP method is POST unless
+// set with the "method" query parameter.
+//
+// Each client message is a request message in JSON, an empty message ends the client stream. Each response
+// message is sent as a message in JSON. The connection is closed with normal closure once the RPC succeeds, or
+// with 4000 plus the gRPC code after sending the error response when it fails.
+// Apply to GRPCRest server instances.
+func WithWebSocket(config WebSocketConfig) Option {
+	if config.PingInterval == 0 {
+		config.PingInterval = defaultWebSocketPingInterval
+	}
+
+	if config.ReadLimit == 0 {
+		config.ReadLimit = defaultWebSocketReadLimit
+	}
+
+	forwardHeaders := append(slices.Clone(webSocketForwardHeaders), config.ForwardHeaders...)
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+
+			return origin == "" || sameOrigin(origin, r) ||
+				slices.Contains(config.AllowedOrigins, "*") || slices.Contains(config.AllowedOrigins, origin)
+		},
+	}
+
+	return func(srv any) {
+		s, ok := srv.(*GRPCRest)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		s.options.middlewares = append(s.options.middlewares, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !websocket.IsWebSocketUpgrade(r) {
+					next.ServeHTTP(w, r)
+
+					return
+				}
+
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					// The upgrader replied with the error.
+					return
+				}
+
+				defer conn.Close() //nolint:errcheck
+
+				conn.SetReadLimit(config.ReadLimit)
+
+				bridgeWebSocket(conn, r, next, forwardHeaders, config.PingInterval)
+			})
+		})
+	}
+}
+
+// bridgeWebSocket serves the RPC of the request with the gateway, reading the request messages from and writing the
+// response messages to the connection.
+func bridgeWebSocket(conn *websocket.Conn, r *http.Request, gateway http.Handler, forwardHeaders []string, pingInterval time.Duration) {
+	ww := &webSocketResponseWriter{conn: conn, header: make(http.Header)}
+
+	ctx, cancel := context.WithCancel(context.WithValue(r.Context(), webSocketResponseKey{}, ww))
+	defer cancel()
+
+	pr, pw := io.Pipe()
+
+	req := r.Clone(ctx)
+	req.Method = http.MethodPost
+	req.Body = pr
+	req.ContentLength = -1
+
+	if m := r.URL.Query().Get("method"); m != "" {
+		req.Method = strings.ToUpper(m)
+	}
+
+	for k := range req.Header {
+		if k == "Upgrade" || k == "Connection" || strings.HasPrefix(k, "Sec-Websocket-") {
+			req.Header.Del(k)
+		}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for _, h := range forwardHeaders {
+		// The gateway forwards Authorization as the authorization metadata.
+		if strings.EqualFold(h, "Authorization") {
+			continue
+		}
+
+		// Replaces the value set by HTTPRequestID, if any.
+		req.Header.Del(runtime.MetadataHeaderPrefix + h)
+
+		for _, v := range r.Header.Values(h) {
+			req.Header.Add(runtime.MetadataHeaderPrefix+h, v)
+		}
+	}
+
+	go readWebSocket(conn, pw, ww, cancel)
+
+	if pingInterval > 0 {
+		go pingWebSocket(ctx, conn, pingInterval)
+	}
+
+	gateway.ServeHTTP(ww, req)
+
+	ww.finish()
+}
+
+// readWebSocket writes the client messages as JSON lines, until the empty message ending the client stream.
+// It keeps reading, for the control frames to be handled, and cancels the call once the connection is closed or
+// a message is not valid JSON.
+func readWebSocket(conn *websocket.Conn, pw *io.PipeWriter, ww *webSocketResponseWriter, cancel context.CancelFunc) {
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			pw.CloseWithError(err)
+			cancel()
+
+			return
+		}
+
+		if len(bytes.TrimSpace(msg)) == 0 {
+			_ = pw.Close() //nolint:errcheck
+
+			continue
+		}
+
+		if !json.Valid(msg) {
+			ww.fail(codes.InvalidArgument, "invalid message")
+			pw.CloseWithError(ErrInvalidWebSocketMessage)
+			cancel()
+
+			return
+		}
+
+		// Messages sent once the client stream ended are dropped.
+		_, _ = pw.Write(append(msg, '\n')) //nolint:errcheck
+	}
+}
+
+// pingWebSocket sends a ping every interval until the call ends.
+func pingWebSocket(ctx context.Context, conn *websocket.Conn, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// webSocketResponseWriter translates the newline delimited JSON of the gateway streams to messages. Other
+// responses, e.g. errors before the stream starts, are sent as a single message.
+type webSocketResponseWriter struct {
+	conn   *websocket.Conn
+	header http.Header
+
+	mu      sync.Mutex
+	status  int
+	decided bool
+	stream  bool
+	buf     []byte
+
+	// closeCode and closeReason are set by the error ending the call.
+	closeCode   int
+	closeReason string
+
+	// errStatus is the status of the error response written by the gateway error handler.
+	errStatus *status.Status
+}
+
+// webSocketResponseKey is the context key of the webSocketResponseWriter of the call.
+type webSocketResponseKey struct{}
+
+// setWebSocketErrorStatus records the status of the error response of a call bridged to a WebSocket connection,
+// so the connection is closed with its code.
+func setWebSocketErrorStatus(ctx context.Context, st *status.Status) {
+	ww, ok := ctx.Value(webSocketResponseKey{}).(*webSocketResponseWriter)
+	if !ok {
+		return
+	}
+
+	ww.mu.Lock()
+	defer ww.mu.Unlock()
+
+	ww.errStatus = st
+}
+
+func (ww *webSocketResponseWriter) Header() http.Header {
+	return ww.header
+}
+
+func (ww *webSocketResponseWriter) decide() {
+	if ww.decided {
+		return
+	}
+
+	ww.decided = true
+	ww.stream = ww.header.Get("Transfer-Encoding") == "chunked"
+
+	if ww.status == 0 {
+		ww.status = http.StatusOK
+	}
+}
+
+func (ww *webSocketResponseWriter) WriteHeader(code int) {
+	ww.mu.Lock()
+	defer ww.mu.Unlock()
+
+	if ww.status == 0 {
+		ww.status = code
+	}
+
+	ww.decide()
+}
+
+func (ww *webSocketResponseWriter) Write(b []byte) (int, error) {
+	ww.mu.Lock()
+	defer ww.mu.Unlock()
+
+	ww.decide()
+
+	ww.buf = append(ww.buf, b...)
+
+	if !ww.stream {
+		return len(b), nil
+	}
+
+	for {
+		i := bytes.IndexByte(ww.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := bytes.TrimSpace(ww.buf[:i])
+		ww.buf = ww.buf[i+1:]
+
+		if len(line) == 0 {
+			continue
+		}
+
+		if err := ww.writeChunk(line); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// writeChunk sends a gateway stream chunk, either {"result": message} or {"error": status}.
+func (ww *webSocketResponseWriter) writeChunk(chunk []byte) error {
+	var c struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+
+	if err := json.Unmarshal(chunk, &c); err != nil {
+		c.Result = chunk
+	}
+
+	if c.Error == nil {
+		return ww.conn.WriteMessage(websocket.TextMessage, c.Result)
+	}
+
+	if ww.closeCode != 0 {
+		// The call already failed.
+		return nil
+	}
+
+	var p spb.Status
+
+	st := status.New(codes.Internal, "malformed stream error")
+
+	if err := protojson.Unmarshal(c.Error, &p); err == nil {
+		st = status.FromProto(&p)
+	}
+
+	ww.closeCode = webSocketStatusCloseCode + int(st.Code())
+	ww.closeReason = st.Message()
+
+	data, err := json.Marshal(newErrorResponse(st, runtime.HTTPStatusFromCode(st.Code())))
+	if err != nil {
+		return err
+	}
+
+	return ww.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// fail sets the close code and reason of the call, unless it already failed.
+func (ww *webSocketResponseWriter) fail(code codes.Code, reason string) {
+	ww.mu.Lock()
+	defer ww.mu.Unlock()
+
+	if ww.closeCode == 0 {
+		ww.closeCode = webSocketStatusCloseCode + int(code)
+		ww.closeReason = reason
+	}
+}
+
+// Flush implements http.Flusher, messages are sent as they are written.
+func (ww *webSocketResponseWriter) Flush() {}
+
+// finish sends the response when it was not streamed and closes the connection.
+func (ww *webSocketResponseWriter) finish() {
+	ww.mu.Lock()
+	defer ww.mu.Unlock()
+
+	ww.decide()
+
+	if !ww.stream {
+		if len(bytes.TrimSpace(ww.buf)) > 0 {
+			_ = ww.conn.WriteMessage(websocket.TextMessage, bytes.TrimSpace(ww.buf)) //nolint:errcheck
+		}
+
+		if ww.status >= http.StatusBadRequest && ww.closeCode == 0 {
+			if ww.errStatus != nil {
+				ww.closeCode = webSocketStatusCloseCode + int(ww.errStatus.Code())
+				ww.closeReason = ww.errStatus.Message()
+			} else {
+				// The error response was not written by the gateway error handler, e.g. by a middleware.
+				var e errorResponse
+
+				_ = json.Unmarshal(ww.buf, &e) //nolint:errcheck
+
+				ww.closeCode = webSocketStatusCloseCode + int(codeFromHTTPStatus(ww.status))
+				ww.closeReason = e.Message
+			}
+		}
+	}
+
+	code := ww.closeCode
+	if code == 0 {
+		code = websocket.CloseNormalClosure
+	}
+
+	// The reason is cut on a rune boundary, clients fail the connections closed with an invalid UTF-8 reason.
+	reason := ww.closeReason
+	if len(reason) > webSocketMaxCloseReason {
+		i := webSocketMaxCloseReason
+		for i > 0 && !utf8.RuneStart(reason[i]) {
+			i--
+		}
+
+		reason = reason[:i]
+	}
+
+	_ = ww.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), //nolint:errcheck
+		time.Now().Add(time.Second))
+}
+
+// codeFromHTTPStatus returns the gRPC code of the HTTP status of an error response, the reverse of
+// runtime.HTTPStatusFromCode. Several codes share a status, so it is only used for the error responses without a
+// gRPC status.
+func codeFromHTTPStatus(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusInternalServerError:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
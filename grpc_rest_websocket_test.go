@@ -0,0 +1,159 @@
+package servers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bool64/ctxd"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/serverstest"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// chatWebSocket sends the messages on a WebSocket connection to the URL, ending the client stream, and returns
+// the received messages and the close error.
+func chatWebSocket(t *testing.T, url string, header http.Header, messages ...string) ([]string, *websocket.CloseError) {
+	t.Helper()
+
+	conn, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http"), header)
+	require.NoError(t, err)
+
+	defer res.Body.Close() //nolint:errcheck
+	defer conn.Close()     //nolint:errcheck
+
+	for _, m := range append(messages, "") {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(m)))
+	}
+
+	var received []string
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+
+			require.ErrorAs(t, err, &closeErr)
+
+			return received, closeErr
+		}
+
+		received = append(received, string(msg))
+	}
+}
+
+func TestGRPCRest_WithWebSocket(t *testing.T) {
+	var (
+		mu sync.Mutex
+		md metadata.MD
+	)
+
+	s := serverstest.Start(t,
+		serverstest.WithGRPC(
+			servers.WithRegisterService(newGRPCTestServer(ctxd.NoOpLogger{})),
+			servers.WithStageInterceptor(servers.StageAuth, "metadata", nil,
+				func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+					mu.Lock()
+					md, _ = metadata.FromIncomingContext(ss.Context())
+					mu.Unlock()
+
+					return handler(srv, ss)
+				},
+			),
+			servers.WithStageInterceptor(servers.StageAuth, "reject",
+				func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+					if md, _ := metadata.FromIncomingContext(ctx); slices.Contains(md.Get("x-request-id"), "reject") {
+						return nil, status.Error(codes.DataLoss, strings.Repeat("é", 100))
+					}
+
+					return handler(ctx, req)
+				}, nil,
+			),
+		),
+		serverstest.WithGateway(gRPCRestStreamTestServer{}, gRPCRestConnTestServer{}),
+		serverstest.WithGRPCRest(servers.WithWebSocket(servers.WebSocketConfig{})),
+	)
+
+	t.Run("bidirectional", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("Authorization", "Bearer token")
+		header.Set("X-Request-Id", "request-1")
+
+		received, closeErr := chatWebSocket(t, s.GRPCRestURL+"/chat", header, `{"name":"a"}`, `{"name":"b"}`)
+
+		assert.Equal(t, []string{`{"message":"Hello a"}`, `{"message":"Hello b"}`}, received)
+		assert.Equal(t, websocket.CloseNormalClosure, closeErr.Code)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, []string{"Bearer token"}, md.Get("authorization"))
+		assert.Equal(t, []string{"request-1"}, md.Get("x-request-id"))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		received, closeErr := chatWebSocket(t, s.GRPCRestURL+"/chat", nil, `{"name":"a"}`, `{"name":"fail"}`)
+
+		require.Len(t, received, 2)
+		assert.Equal(t, `{"message":"Hello a"}`, received[0])
+
+		var e struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+
+		require.NoError(t, json.Unmarshal([]byte(received[1]), &e))
+		assert.Equal(t, http.StatusBadRequest, e.Code)
+		assert.Equal(t, "chat failed", e.Message)
+
+		assert.Equal(t, 4000+int(codes.FailedPrecondition), closeErr.Code)
+		assert.Equal(t, "chat failed", closeErr.Text)
+	})
+
+	t.Run("invalid message", func(t *testing.T) {
+		_, closeErr := chatWebSocket(t, s.GRPCRestURL+"/chat", nil, `{"name":`)
+
+		assert.Equal(t, 4000+int(codes.InvalidArgument), closeErr.Code)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, closeErr := chatWebSocket(t, s.GRPCRestURL+"/unknown", nil)
+
+		assert.Equal(t, 4000+int(codes.NotFound), closeErr.Code)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("X-Request-Id", "reject")
+
+		_, closeErr := chatWebSocket(t, s.GRPCRestURL+"/say/test?method=get", header)
+
+		// DataLoss shares its HTTP status with Internal and Unknown.
+		assert.Equal(t, 4000+int(codes.DataLoss), closeErr.Code)
+		assert.True(t, utf8.ValidString(closeErr.Text))
+		assert.Equal(t, strings.Repeat("é", 61), closeErr.Text)
+	})
+
+	t.Run("origin", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("Origin", "https://example.com")
+
+		_, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.GRPCRestURL, "http")+"/chat", header)
+		require.Error(t, err)
+
+		defer res.Body.Close() //nolint:errcheck
+
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
 )
 
 // Status references the status.Status from google.golang.org/grpc/status.
@@ -179,3 +180,36 @@ func (e *errSt) Fields() map[string]any {
 
 	return errStuctured.Fields()
 }
+
+// errorIDFromStatus returns the error_id carried in the ErrorInfo details of the status, if any.
+func errorIDFromStatus(st *status.Status) string {
+	for _, d := range st.Details() {
+		errInfo, ok := d.(*errdetails.ErrorInfo)
+		if !ok {
+			continue
+		}
+
+		if id, ok := errInfo.GetMetadata()["error_id"]; ok {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// withStatusDetails appends the details to the status of an error created by Error or WrapError, keeping its
+// ErrorInfo. Other errors are returned as they are.
+func withStatusDetails(err error, details ...protoadapt.MessageV1) error {
+	var e *errSt
+
+	if !errors.As(err, &e) {
+		return err
+	}
+
+	st, derr := e.s.Status.WithDetails(details...)
+	if derr != nil {
+		panic(derr)
+	}
+
+	return (&Status{Status: st, err: e.s.err}).Err()
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -24,13 +25,16 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type gRPCTestServer struct {
-	// UnimplementedGreeterServer must be embedded to have forward compatible implementations.
+	// UnimplementedGreeterServer and UnimplementedStreamGreeterServer must be embedded to have forward compatible
+	// implementations.
 	testdata.UnimplementedGreeterServer
+	testdata.UnimplementedStreamGreeterServer
 
 	logger ctxd.Logger
 }
@@ -47,9 +51,50 @@ func (srv *gRPCTestServer) SayHello(ctx context.Context, req *testdata.HelloRequ
 	return &testdata.HelloReply{Message: "Hello " + req.GetName()}, nil
 }
 
+func (srv *gRPCTestServer) SayHellos(req *testdata.HelloRequest, stream grpc.ServerStreamingServer[testdata.HelloReply]) error {
+	for _, name := range strings.Split(req.GetName(), ",") {
+		switch name {
+		case "fail":
+			return servers.Error(codes.FailedPrecondition, "stream failed", map[string]string{"name": name})
+		case "wait":
+			time.Sleep(100 * time.Millisecond)
+
+			continue
+		}
+
+		if err := stream.Send(&testdata.HelloReply{Message: "Hello " + name}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (srv *gRPCTestServer) Chat(stream grpc.BidiStreamingServer[testdata.HelloRequest, testdata.HelloReply]) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if req.GetName() == "fail" {
+			return servers.Error(codes.FailedPrecondition, "chat failed", map[string]string{"name": req.GetName()})
+		}
+
+		if err := stream.Send(&testdata.HelloReply{Message: "Hello " + req.GetName()}); err != nil {
+			return err
+		}
+	}
+}
+
 // RegisterService registers the service implementation to grpc service.
 func (srv *gRPCTestServer) RegisterService(sr grpc.ServiceRegistrar) {
 	testdata.RegisterGreeterServer(sr, srv)
+	testdata.RegisterStreamGreeterServer(sr, srv)
 }
 
 func startGRPCService(logger ctxd.Logger, shutdownDoneCh, shutdownCh chan struct{}, ops ...servers.Option) (*servers.GRPC, string, error) {
@@ -331,3 +376,76 @@ func TestGRPC_StartServing_WithMetrics(t *testing.T) {
 
 	assert.Equal(t, 1, n)
 }
+
+// recordingObserver is a servers.GRPCObserver recording the order it observes the calls in.
+type recordingObserver struct {
+	name  string
+	calls *[]string
+}
+
+func (o recordingObserver) UnaryServerInterceptor() func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		*o.calls = append(*o.calls, o.name)
+
+		return handler(ctx, req)
+	}
+}
+
+func (o recordingObserver) StreamServerInterceptor() func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		*o.calls = append(*o.calls, o.name)
+
+		return handler(srv, ss)
+	}
+}
+
+func TestGRPC_WithGRPCObserver_Chain(t *testing.T) {
+	var calls []string
+
+	srv, addr, err := startGRPCService(nil, nil, nil,
+		// The rate limiter runs after the observers regardless of the options order.
+		servers.WithRateLimiter(servers.NewPerClientRateLimiter(0.001, 1)),
+		servers.WithGRPCObserver(
+			recordingObserver{name: "first", calls: &calls},
+			recordingObserver{name: "second", calls: &calls},
+		),
+	)
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer srv.Stop()
+
+	c := testdata.NewGreeterClient(dialGRPC(t, addr))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	require.NoError(t, err)
+
+	_, err = c.SayHello(ctx, &testdata.HelloRequest{Name: "test"})
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"first", "second", "first", "second"}, calls)
+
+	err = servers.NewGRPC(servers.Config{},
+		servers.WithGRPCObserver(recordingObserver{name: "first", calls: &calls}),
+		servers.WithGRPCObserver(recordingObserver{name: "second", calls: &calls}),
+	).Start()
+	require.ErrorIs(t, err, servers.ErrGRPCStart)
+	assert.ErrorIs(t, err, servers.ErrGRPCObserverSet)
+
+	err = servers.NewGRPC(servers.Config{},
+		servers.WithRateLimiter(servers.NewPerClientRateLimiter(1, 1)),
+		servers.WithGRPCRateLimiter(servers.NewPerClientRateLimiter(1, 1)),
+	).Start()
+	require.ErrorIs(t, err, servers.ErrGRPCStart)
+	assert.ErrorIs(t, err, servers.ErrGRPCRateLimiterSet)
+
+	err = servers.NewGRPC(servers.Config{},
+		servers.WithGRPCRateLimiter(servers.NewPerClientRateLimiter(1, 1,
+			servers.WithRules(servers.RateLimitRule{Name: "invalid", RPS: -1}),
+		)),
+	).Start()
+	require.ErrorIs(t, err, servers.ErrGRPCStart)
+	assert.ErrorIs(t, err, servers.ErrInvalidRateLimitRule)
+}
@@ -0,0 +1,360 @@
+package servers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// gRPC-Web content types.
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+)
+
+// grpcWebTrailerFlag marks the trailer frame in a gRPC-Web response body.
+const grpcWebTrailerFlag = 0x80
+
+// grpcWebDefaultHeaders are the request headers always allowed by CORS, sent by the gRPC-Web clients.
+var grpcWebDefaultHeaders = []string{
+	"Content-Type", "X-Grpc-Web", "X-User-Agent", "Grpc-Timeout", "Authorization", "X-Request-Id",
+}
+
+// grpcWebExposedHeaders are the response headers always exposed by CORS, read by the gRPC-Web clients.
+var grpcWebExposedHeaders = []string{
+	"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin",
+}
+
+// GRPCWebConfig contains configuration options for the gRPC-Web handler.
+type GRPCWebConfig struct {
+	// AllowedOrigins are the origins allowed to call the services, "*" allows any origin.
+	// Empty disables CORS, only same origin calls are allowed.
+	AllowedOrigins []string
+	// AllowedHeaders are request headers allowed on top of the ones sent by the gRPC-Web clients, e.g. custom
+	// metadata keys.
+	AllowedHeaders []string
+	// ExposedHeaders are response headers exposed on top of the gRPC status ones, e.g. custom metadata keys.
+	ExposedHeaders []string
+	// AllowCredentials allows the browser to send cookies and HTTP authentication.
+	AllowCredentials bool
+	// MaxAge is the time the preflight responses are cached by the browser.
+	MaxAge time.Duration
+}
+
+// isGRPCWebRequest tells whether the request is a gRPC-Web call or its CORS preflight.
+func isGRPCWebRequest(r *http.Request) bool {
+	if r.Method == http.MethodOptions {
+		for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			if strings.EqualFold(strings.TrimSpace(h), "x-grpc-web") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), grpcWebContentType)
+}
+
+// NewGRPCWebHandler creates a handler serving the services of the GRPC server to gRPC-Web clients, in binary
+// and text modes, including server streaming. Calls go through the GRPC server, so they run its interceptor
+// chain and reach its health service.
+//
+// It can be served alone, e.g. with NewREST, or along the gateway with WithGRPCWeb.
+func NewGRPCWebHandler(grpcSrv *GRPC, config GRPCWebConfig) http.Handler {
+	h := grpcWebHandler{
+		server:         grpcSrv,
+		config:         config,
+		allowedHeaders: strings.Join(append(slices.Clone(grpcWebDefaultHeaders), config.AllowedHeaders...), ", "),
+		exposedHeaders: strings.Join(append(slices.Clone(grpcWebExposedHeaders), config.ExposedHeaders...), ", "),
+	}
+
+	return h
+}
+
+// WithGRPCWeb serves the services of the GRPC server to gRPC-Web clients along the gateway, see
+// NewGRPCWebHandler. gRPC-Web calls are told apart by their content type.
+// Apply to GRPCRest server instances.
+func WithGRPCWeb(grpcSrv *GRPC, config GRPCWebConfig) Option {
+	return func(srv any) {
+		s, ok := srv.(*GRPCRest)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		grpcWeb := NewGRPCWebHandler(grpcSrv, config)
+
+		s.options.middlewares = append(s.options.middlewares, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if isGRPCWebRequest(r) {
+					grpcWeb.ServeHTTP(w, r)
+
+					return
+				}
+
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+}
+
+type grpcWebHandler struct {
+	server *GRPC
+	config GRPCWebConfig
+
+	allowedHeaders string
+	exposedHeaders string
+}
+
+func (h grpcWebHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Browsers send Origin on same origin POST calls too, they need no CORS headers.
+	if origin := r.Header.Get("Origin"); origin != "" && !sameOrigin(origin, r) {
+		if !h.allowOrigin(w, origin) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+
+			return
+		}
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", http.MethodPost)
+			w.Header().Set("Access-Control-Allow-Headers", h.allowedHeaders)
+
+			if h.config.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(h.config.MaxAge.Seconds())))
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	text := strings.HasPrefix(contentType, grpcWebTextContentType)
+
+	rw := &grpcWebResponseWriter{
+		w:           w,
+		header:      make(http.Header),
+		text:        text,
+		contentType: contentType,
+	}
+
+	body := r.Body
+
+	if text {
+		// The body is the base64 encoding of a message frame, at most 4/3 of its size.
+		limit := base64.StdEncoding.EncodedLen(h.server.maxRecvMsgSize() + 5)
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(limit)))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if !errors.As(err, &maxErr) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			rw.WriteHeader(http.StatusOK)
+			rw.Header().Set("Grpc-Status", strconv.Itoa(int(codes.ResourceExhausted)))
+			rw.Header().Set("Grpc-Message", url.PathEscape(err.Error()))
+			rw.finish()
+
+			return
+		}
+
+		if data, err = decodeGRPCWebText(data); err != nil {
+			http.Error(w, "malformed base64 body", http.StatusBadRequest)
+
+			return
+		}
+
+		body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	// The call is served by the grpc server as if it came through HTTP/2.
+	req := r.Clone(r.Context())
+	req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2"
+	req.Body = body
+	req.ContentLength = -1
+	req.Header.Del("Content-Length")
+	req.Header.Set("Content-Type", "application/grpc"+grpcWebContentSubtype(contentType))
+
+	h.server.grpcServer.ServeHTTP(rw, req)
+
+	rw.finish()
+}
+
+// allowOrigin sets the CORS headers of the allowed origin.
+func (h grpcWebHandler) allowOrigin(w http.ResponseWriter, origin string) bool {
+	if !slices.Contains(h.config.AllowedOrigins, "*") && !slices.Contains(h.config.AllowedOrigins, origin) {
+		return false
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Add("Vary", "Origin")
+	w.Header().Set("Access-Control-Expose-Headers", h.exposedHeaders)
+
+	if h.config.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	return true
+}
+
+// sameOrigin tells whether the origin is the host the request is sent to.
+func sameOrigin(origin string, r *http.Request) bool {
+	u, err := url.Parse(origin)
+
+	return err == nil && u.Host != "" && u.Host == r.Host
+}
+
+// grpcWebContentSubtype returns the codec suffix of the content type, e.g. "+proto".
+func grpcWebContentSubtype(contentType string) string {
+	if i := strings.IndexByte(contentType, '+'); i >= 0 {
+		return contentType[i:]
+	}
+
+	return ""
+}
+
+// decodeGRPCWebText decodes a base64 body, which may be made of several padded chunks.
+func decodeGRPCWebText(data []byte) ([]byte, error) {
+	data = bytes.Join(bytes.Fields(data), nil)
+	out := make([]byte, 0, base64.StdEncoding.DecodedLen(len(data)))
+
+	for len(data) > 0 {
+		// Chunks end at the first group with padding.
+		end := len(data)
+
+		if i := bytes.IndexByte(data, '='); i >= 0 {
+			end = min(len(data), (i/4+1)*4)
+		}
+
+		chunk := make([]byte, base64.StdEncoding.DecodedLen(end))
+
+		n, err := base64.StdEncoding.Decode(chunk, data[:end])
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, chunk[:n]...)
+		data = data[end:]
+	}
+
+	return out, nil
+}
+
+// grpcWebResponseWriter translates the gRPC response written by the grpc server to gRPC-Web, sending the
+// trailers as the last frame of the body.
+type grpcWebResponseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	wroteHeader bool
+
+	text        bool
+	contentType string
+}
+
+func (rw *grpcWebResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *grpcWebResponseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+
+	rw.wroteHeader = true
+
+	h := rw.w.Header()
+
+	for k, vv := range rw.header {
+		if k == "Trailer" || strings.HasPrefix(k, "Trailer:") {
+			continue
+		}
+
+		h[k] = vv
+	}
+
+	// Headers set from now on are trailers.
+	rw.header = make(http.Header)
+
+	if code == http.StatusOK {
+		h.Set("Content-Type", rw.contentType)
+	}
+
+	rw.w.WriteHeader(code)
+}
+
+func (rw *grpcWebResponseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if !rw.text {
+		return rw.w.Write(b)
+	}
+
+	if _, err := rw.w.Write([]byte(base64.StdEncoding.EncodeToString(b))); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+func (rw *grpcWebResponseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish writes the trailers frame.
+func (rw *grpcWebResponseWriter) finish() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if len(rw.header) == 0 {
+		return
+	}
+
+	var trailers bytes.Buffer
+
+	for k, vv := range rw.header {
+		k = strings.ToLower(strings.TrimPrefix(k, "Trailer:"))
+
+		for _, v := range vv {
+			trailers.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+
+	frame := make([]byte, 5, 5+trailers.Len())
+	frame[0] = grpcWebTrailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(trailers.Len())) //nolint:gosec
+	frame = append(frame, trailers.Bytes()...)
+
+	_, _ = rw.Write(frame) //nolint:errcheck
+
+	rw.Flush()
+}
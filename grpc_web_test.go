@@ -0,0 +1,211 @@
+package servers_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// grpcWebCall calls the method with the gRPC-Web protocol, returning the response messages and trailers.
+func grpcWebCall(t *testing.T, url, contentType string, req proto.Message) ([][]byte, string) {
+	t.Helper()
+
+	msg, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg))) //nolint:gosec
+	body = append(body, msg...)
+
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+	if text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("X-Grpc-Web", "1")
+
+	res, err := http.DefaultClient.Do(httpReq)
+	require.NoError(t, err)
+
+	defer res.Body.Close() //nolint:errcheck
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, contentType, res.Header.Get("Content-Type"))
+
+	data, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	if text {
+		// Each write is encoded apart, padded.
+		var decoded []byte
+
+		for len(data) > 0 {
+			end := len(data)
+			if i := bytes.IndexByte(data, '='); i >= 0 {
+				end = min(len(data), (i/4+1)*4)
+				for end < len(data) && data[end] == '=' {
+					end++
+				}
+			}
+
+			chunk, err := base64.StdEncoding.DecodeString(string(data[:end]))
+			require.NoError(t, err)
+
+			decoded = append(decoded, chunk...)
+			data = data[end:]
+		}
+
+		data = decoded
+	}
+
+	var (
+		messages [][]byte
+		trailers string
+	)
+
+	for len(data) >= 5 {
+		n := binary.BigEndian.Uint32(data[1:5])
+		frame := data[5 : 5+n]
+
+		if data[0]&0x80 != 0 {
+			trailers = string(frame)
+		} else {
+			messages = append(messages, frame)
+		}
+
+		data = data[5+n:]
+	}
+
+	return messages, trailers
+}
+
+func TestGRPCRest_WithGRPCWeb(t *testing.T) {
+	grpcSrv, grpcAddr, err := startGRPCService(nil, nil, nil, servers.WithGrpcHealthCheck())
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer grpcSrv.Stop()
+
+	srv, addr, err := startGRPCRestService(grpcAddr, nil, nil, servers.WithGRPCWeb(grpcSrv, servers.GRPCWebConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+	}))
+	require.NoErrorf(t, err, "start GRPC Rest: %v", err)
+
+	defer srv.Stop()
+
+	base := fmt.Sprintf("http://%s", addr)
+
+	for _, contentType := range []string{"application/grpc-web+proto", "application/grpc-web-text"} {
+		t.Run(contentType, func(t *testing.T) {
+			messages, trailers := grpcWebCall(t, base+"/helloworld.Greeter/SayHello", contentType, &testdata.HelloRequest{Name: "test"})
+			require.Len(t, messages, 1)
+
+			var reply testdata.HelloReply
+
+			require.NoError(t, proto.Unmarshal(messages[0], &reply))
+			assert.Equal(t, "Hello test", reply.GetMessage())
+			assert.Contains(t, trailers, "grpc-status: 0\r\n")
+
+			// Server streaming.
+			messages, trailers = grpcWebCall(t, base+"/helloworld.StreamGreeter/SayHellos", contentType, &testdata.HelloRequest{Name: "a,b"})
+			require.Len(t, messages, 2)
+			assert.Contains(t, trailers, "grpc-status: 0\r\n")
+		})
+	}
+
+	// Health is served too.
+	messages, _ := grpcWebCall(t, base+"/grpc.health.v1.Health/Check", "application/grpc-web", &grpc_health_v1.HealthCheckRequest{})
+	require.Len(t, messages, 1)
+
+	var health grpc_health_v1.HealthCheckResponse
+
+	require.NoError(t, proto.Unmarshal(messages[0], &health))
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, health.GetStatus())
+
+	// Errors are sent in the trailers.
+	_, trailers := grpcWebCall(t, base+"/helloworld.Greeter/Unknown", "application/grpc-web", &testdata.HelloRequest{})
+	assert.Contains(t, trailers, "grpc-status: 12\r\n")
+
+	// CORS preflight.
+	preflight := func(origin string) *http.Response {
+		req, err := http.NewRequest(http.MethodOptions, base+"/helloworld.Greeter/SayHello", nil)
+		require.NoError(t, err)
+
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		return res
+	}
+
+	res := preflight("https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Equal(t, "https://app.example.com", res.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, res.Header.Get("Access-Control-Allow-Headers"), "X-Grpc-Web")
+
+	assert.Equal(t, http.StatusForbidden, preflight("https://evil.example.com").StatusCode)
+
+	// Same origin calls carry Origin too, they are allowed without CORS headers.
+	req, err := http.NewRequest(http.MethodPost, base+"/helloworld.Greeter/SayHello", bytes.NewReader(make([]byte, 5)))
+	require.NoError(t, err)
+
+	req.Header.Set("Origin", base)
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Empty(t, res.Header.Get("Access-Control-Allow-Origin"))
+
+	// The gateway keeps serving the REST calls.
+	res, err = http.Get(base + "/say/test")
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestGRPCRest_WithGRPCWeb_maxRecvMsgSize(t *testing.T) {
+	grpcSrv, grpcAddr, err := startGRPCService(nil, nil, nil, servers.WithMaxRecvMsgSize(1024))
+	require.NoErrorf(t, err, "start GRPC: %v", err)
+
+	defer grpcSrv.Stop()
+
+	srv, addr, err := startGRPCRestService(grpcAddr, nil, nil, servers.WithGRPCWeb(grpcSrv, servers.GRPCWebConfig{}))
+	require.NoErrorf(t, err, "start GRPC Rest: %v", err)
+
+	defer srv.Stop()
+
+	url := fmt.Sprintf("http://%s/helloworld.Greeter/SayHello", addr)
+
+	for _, contentType := range []string{"application/grpc-web+proto", "application/grpc-web-text"} {
+		t.Run(contentType, func(t *testing.T) {
+			messages, trailers := grpcWebCall(t, url, contentType, &testdata.HelloRequest{Name: strings.Repeat("a", 1000)})
+			require.Len(t, messages, 1)
+			assert.Contains(t, trailers, "grpc-status: 0\r\n")
+
+			messages, trailers = grpcWebCall(t, url, contentType, &testdata.HelloRequest{Name: strings.Repeat("a", 2048)})
+			assert.Empty(t, messages)
+			assert.Contains(t, trailers, "grpc-status: 8\r\n")
+		})
+	}
+}
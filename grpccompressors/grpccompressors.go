@@ -0,0 +1,115 @@
+// Package grpccompressors registers the gzip and zstd gRPC compressors on import:
+//
+//	import _ "github.com/dohernandez/servers/grpccompressors"
+//
+// The gRPC compressor registry is global, so the compressors are available to every gRPC server and client of the
+// process, not only to the servers configured with servers.WithGRPCCompressors.
+package grpccompressors
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/gzip" // Registers the gzip gRPC compressor.
+)
+
+// Zstd is the name of the zstd gRPC compressor.
+const Zstd = "zstd"
+
+func init() {
+	encoding.RegisterCompressor(&zstdCompressor{})
+}
+
+// zstdDecoderMaxMemory is the maximum memory in bytes the zstd decoder allocates for the window of a frame.
+const zstdDecoderMaxMemory = 64 << 20
+
+// zstdCompressor is the gRPC zstd compressor. The messages are streamed through the encoders and decoders, so the
+// gRPC message size limits apply to the decompressed messages.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+// Name implements encoding.Compressor.
+func (*zstdCompressor) Name() string {
+	return Zstd
+}
+
+// Compress implements encoding.Compressor.
+func (gc *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	enc, ok := gc.encoders.Get().(*zstd.Encoder)
+	if !ok {
+		var err error
+
+		enc, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	enc.Reset(w)
+
+	return &zstdWriter{Encoder: enc, pool: &gc.encoders}, nil
+}
+
+// Decompress implements encoding.Compressor.
+func (gc *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, ok := gc.decoders.Get().(*zstd.Decoder)
+	if !ok {
+		var err error
+
+		// A single goroutine decodes synchronously, so the decoders not read to the end are not leaked.
+		dec, err = zstd.NewReader(nil,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(zstdDecoderMaxMemory),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := dec.Reset(r); err != nil {
+		gc.decoders.Put(dec)
+
+		return nil, err
+	}
+
+	return &zstdReader{Decoder: dec, pool: &gc.decoders}, nil
+}
+
+// zstdWriter returns the encoder to the pool on Close.
+type zstdWriter struct {
+	*zstd.Encoder
+
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	defer w.pool.Put(w.Encoder)
+
+	return w.Encoder.Close()
+}
+
+// zstdReader returns the decoder to the pool once the message is read.
+type zstdReader struct {
+	*zstd.Decoder
+
+	pool *sync.Pool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.Decoder == nil {
+		return 0, io.EOF
+	}
+
+	n, err := r.Decoder.Read(p)
+	if errors.Is(err, io.EOF) {
+		r.pool.Put(r.Decoder)
+		r.Decoder = nil
+	}
+
+	return n, err
+}
@@ -11,6 +11,7 @@ import (
 	v3 "github.com/swaggest/swgui/v3"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 )
@@ -110,6 +111,43 @@ func xhttpCodeResponseModifier() func(ctx context.Context, w http.ResponseWriter
 	}
 }
 
+// rateLimitHeaders maps the rate limit header metadata keys to the HTTP headers.
+var rateLimitHeaders = map[string]string{
+	rateLimitLimitKey:     "RateLimit-Limit",
+	rateLimitRemainingKey: "RateLimit-Remaining",
+	rateLimitResetKey:     "RateLimit-Reset",
+}
+
+// rateLimitResponseModifier is used to expose the rate limit header metadata sent by the rate limiters as
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+func rateLimitResponseModifier() func(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+	return func(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+		md, ok := runtime.ServerMetadataFromContext(ctx)
+		if !ok {
+			return nil
+		}
+
+		setRateLimitHeaders(w, md.HeaderMD)
+
+		return nil
+	}
+}
+
+// setRateLimitHeaders sets the rate limit headers from the metadata, deleting the grpc-metadata ones.
+func setRateLimitHeaders(w http.ResponseWriter, mds ...metadata.MD) {
+	for key, header := range rateLimitHeaders {
+		w.Header().Del(runtime.MetadataHeaderPrefix + key)
+
+		for _, md := range mds {
+			if vals := md.Get(key); len(vals) > 0 {
+				w.Header().Set(header, vals[0])
+
+				break
+			}
+		}
+	}
+}
+
 type errorResponse struct {
 	Code    int                    `json:"code"`
 	Message string                 `json:"message,omitempty"`
@@ -123,7 +161,7 @@ type errorResponseDetails struct {
 }
 
 func customizeErrorHandler() func(context.Context, *runtime.ServeMux, runtime.Marshaler, http.ResponseWriter, *http.Request, error) {
-	return func(_ context.Context, _ *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, _ *http.Request, err error) {
+	return func(ctx context.Context, _ *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, _ *http.Request, err error) {
 		// Extract gRPC status error
 		st, ok := status.FromError(err)
 		if !ok {
@@ -135,30 +173,18 @@ func customizeErrorHandler() func(context.Context, *runtime.ServeMux, runtime.Ma
 		// Default HTTP status code
 		httpStatus := runtime.HTTPStatusFromCode(st.Code())
 
-		// Transform google.rpc.BadRequest into a simplified structure
-		var (
-			details []errorResponseDetails
-			errID   string
-		)
+		// The calls bridged to WebSocket connections are closed with the gRPC code.
+		setWebSocketErrorStatus(ctx, st)
 
 		for _, d := range st.Details() {
-			errInfo, ok := d.(*errdetails.ErrorInfo)
-			if !ok {
-				continue
+			if retryInfo, ok := d.(*errdetails.RetryInfo); ok {
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(retryInfo.GetRetryDelay().AsDuration()))))
 			}
+		}
 
-			for f, m := range errInfo.GetMetadata() {
-				if f == "error_id" {
-					errID = m
-
-					continue
-				}
-
-				details = append(details, errorResponseDetails{
-					Field:       f,
-					Description: m,
-				})
-			}
+		// Rejected calls may carry the rate limit metadata in the trailers when the response is trailers only.
+		if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+			setRateLimitHeaders(w, md.HeaderMD, md.TrailerMD)
 		}
 
 		// Delete the gRPC metadata from the response
@@ -167,11 +193,42 @@ func customizeErrorHandler() func(context.Context, *runtime.ServeMux, runtime.Ma
 		// Write the custom error response
 		w.WriteHeader(httpStatus)
 
-		_ = json.NewEncoder(w).Encode(errorResponse{ //nolint:errcheck
-			Code:    httpStatus,
-			Message: st.Message(),
-			Error:   errID,
-			Details: details,
-		})
+		_ = json.NewEncoder(w).Encode(newErrorResponse(st, httpStatus)) //nolint:errcheck
+	}
+}
+
+// newErrorResponse returns the error response of the status, the google.rpc.ErrorInfo metadata transformed into
+// the details and the error id.
+func newErrorResponse(st *status.Status, httpStatus int) errorResponse {
+	var (
+		details []errorResponseDetails
+		errID   string
+	)
+
+	for _, d := range st.Details() {
+		errInfo, ok := d.(*errdetails.ErrorInfo)
+		if !ok {
+			continue
+		}
+
+		for f, m := range errInfo.GetMetadata() {
+			if f == "error_id" {
+				errID = m
+
+				continue
+			}
+
+			details = append(details, errorResponseDetails{
+				Field:       f,
+				Description: m,
+			})
+		}
+	}
+
+	return errorResponse{
+		Code:    httpStatus,
+		Message: st.Message(),
+		Error:   errID,
+		Details: details,
 	}
 }
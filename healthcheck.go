@@ -1,8 +1,10 @@
 package servers
 
 import (
+	"context"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -11,6 +13,7 @@ import (
 	healthHttp "github.com/hellofresh/health-go/v5/checks/http"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type healthOptions struct {
@@ -18,8 +21,14 @@ type healthOptions struct {
 
 	grpcRest *GRPCRest
 	grpc     *GRPC
+
+	grpcHealthSync time.Duration
+	grpcHealthDeps GRPCServiceDependencies
 }
 
+// GRPCServiceDependencies maps gRPC service names to the names of the health checks they depend on.
+type GRPCServiceDependencies map[string][]string
+
 // WithHealthCheck sets up health check server.
 func WithHealthCheck(checks ...health.Config) Option {
 	return func(srv any) {
@@ -59,12 +68,36 @@ func WithGRPCRest(grpcRest *GRPCRest) Option {
 	}
 }
 
+// WithGRPCHealthSync drives the gRPC health status of the server set by WithGRPC from the health checks set by
+// WithHealthCheck, measured every interval while the server is running.
+//
+// The services in deps are NOT_SERVING while any of their checks fails. The other services, and the overall
+// status, are NOT_SERVING while the checks report the server unavailable. The services whose status is set
+// manually, see GRPCHealth.SetServingStatus, are skipped until their override is cleared.
+func WithGRPCHealthSync(interval time.Duration, deps GRPCServiceDependencies) Option {
+	return func(srv any) {
+		s, ok := srv.(*HealthCheck)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		s.options.grpcHealthSync = interval
+		s.options.grpcHealthDeps = deps
+	}
+}
+
 // HealthCheck is a listening HTTP server instance with the endpoints "/" and "/health" mainly use for
 // livenessProbe and readinessProbe on Kubernetes cluster.
 type HealthCheck struct {
 	*REST
 
 	options healthOptions
+
+	// deps measures the dependency checks only, to sync the gRPC health status.
+	deps     *health.Health
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 // NewHealthCheck is a listening HTTP server instance with the endpoints "/" and "/health" mainly use for
@@ -72,12 +105,18 @@ type HealthCheck struct {
 //
 //nolint:funlen
 func NewHealthCheck(cfg Config, opts ...Option) *HealthCheck {
-	srv := &HealthCheck{}
+	srv := &HealthCheck{
+		stop: make(chan struct{}),
+	}
 
 	for _, o := range opts {
 		o(srv)
 	}
 
+	if srv.options.grpc != nil && srv.options.grpcHealthSync > 0 {
+		srv.deps, _ = health.New(health.WithChecks(srv.options.checks...)) //nolint:errcheck
+	}
+
 	h, _ := health.New(health.WithSystemInfo()) //nolint:errcheck
 
 	for _, check := range srv.options.checks {
@@ -152,3 +191,77 @@ func NewHealthCheck(cfg Config, opts ...Option) *HealthCheck {
 
 	return srv
 }
+
+// Start starts serving the health check server and, when WithGRPCHealthSync is set, syncing the gRPC health status.
+func (srv *HealthCheck) Start() error {
+	if srv.deps != nil {
+		go srv.syncGRPCHealth()
+	}
+
+	return srv.REST.Start()
+}
+
+// Stop gracefully shuts down the health check server.
+func (srv *HealthCheck) Stop() {
+	srv.stopOnce.Do(func() {
+		close(srv.stop)
+	})
+
+	srv.REST.Stop()
+}
+
+func (srv *HealthCheck) syncGRPCHealth() {
+	t := time.NewTicker(srv.options.grpcHealthSync)
+	defer t.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), srv.options.grpcHealthSync)
+		srv.applyGRPCHealth(srv.deps.Measure(ctx))
+		cancel()
+
+		select {
+		case <-t.C:
+		case <-srv.stop:
+			return
+		}
+
+		// The server may be stopped by the shutdown signal.
+		srv.sm.Lock()
+		stopped := srv.stopped
+		srv.sm.Unlock()
+
+		if stopped {
+			return
+		}
+	}
+}
+
+// applyGRPCHealth sets the gRPC health status of the services from the result of the checks.
+func (srv *HealthCheck) applyGRPCHealth(c health.Check) {
+	h := srv.options.grpc.Health()
+	if h == nil {
+		return
+	}
+
+	overall := healthpb.HealthCheckResponse_SERVING
+
+	if c.Status == health.StatusUnavailable {
+		overall = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	for _, service := range h.Services() {
+		st := overall
+
+		if checks, ok := srv.options.grpcHealthDeps[service]; ok {
+			st = healthpb.HealthCheckResponse_SERVING
+
+			for _, name := range checks {
+				if _, failed := c.Failures[name]; failed {
+					st = healthpb.HealthCheckResponse_NOT_SERVING
+				}
+			}
+		}
+
+		h.syncServingStatus(service, st)
+	}
+}
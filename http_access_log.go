@@ -0,0 +1,86 @@
+package servers
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/bool64/ctxd"
+)
+
+// HTTPAccessLogConfig contains configuration options for the HTTP access logs.
+type HTTPAccessLogConfig struct {
+	// ExcludePaths are the path glob patterns, as in path.Match, of the requests not logged, e.g. "/health".
+	ExcludePaths []string `envconfig:"EXCLUDE_PATHS"`
+	// SuccessSampleRate is the fraction, in (0, 1], of successful requests logged. Zero logs every request.
+	// Failed requests are always logged.
+	SuccessSampleRate float64 `envconfig:"SUCCESS_SAMPLE_RATE"`
+}
+
+// sampled reports whether the successful request is logged.
+func (c HTTPAccessLogConfig) sampled() bool {
+	return c.SuccessSampleRate <= 0 || c.SuccessSampleRate >= 1 || rand.Float64() < c.SuccessSampleRate //nolint:gosec
+}
+
+// WithHTTPAccessLog logs the requests served by the server with HTTPAccessLog.
+// Apply to REST server instances, which includes GRPCRest, HealthCheck and the PProf server, and to Metrics server
+// instances.
+func WithHTTPAccessLog(logger ctxd.Logger, config HTTPAccessLogConfig) Option {
+	return WithHTTPMiddleware(HTTPAccessLog(logger, config))
+}
+
+// HTTPAccessLog logs the served requests with the method, the route pattern, either the chi pattern or the gateway
+// path template, the path, status, size of the response, latency, remote address and request ID.
+// Server errors are logged as errors, client errors as warnings and the other requests as info.
+func HTTPAccessLog(logger ctxd.Logger, config HTTPAccessLogConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if matchFullMethod(config.ExcludePaths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			start := time.Now()
+			rw := newHTTPResponseRecorder(w)
+			r, route := withHTTPRoute(r)
+
+			next.ServeHTTP(rw, r)
+
+			st := rw.Status()
+
+			if st < http.StatusBadRequest && !config.sampled() {
+				return
+			}
+
+			fields := []any{
+				"http.method", r.Method,
+				"http.route", httpRoutePattern(r, route),
+				"http.path", r.URL.Path,
+				"http.status", st,
+				"http.bytes", rw.bytes,
+				"http.latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+				"http.remote_addr", r.RemoteAddr,
+			}
+
+			// HTTPRequestID adds the request ID to the context fields, which is not the context of the request
+			// when it wraps this middleware.
+			if RequestIDFromContext(r.Context()) == "" {
+				if id := r.Header.Get(RequestIDHeader); id != "" {
+					fields = append(fields, "request_id", id)
+				}
+			}
+
+			ctx := ctxd.AddFields(r.Context(), fields...)
+
+			switch {
+			case st >= http.StatusInternalServerError:
+				logger.Error(ctx, "served request")
+			case st >= http.StatusBadRequest:
+				logger.Warn(ctx, "served request")
+			default:
+				logger.Info(ctx, "served request")
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package servers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bool64/ctxd"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/serverstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func accessLogLines(t *testing.T, buff *syncBuffer) []map[string]any {
+	t.Helper()
+
+	var lines []map[string]any
+
+	for _, l := range logLines(t, buff) {
+		if l["message"] == "served request" {
+			lines = append(lines, l)
+		}
+	}
+
+	return lines
+}
+
+func TestWithHTTPAccessLog(t *testing.T) {
+	var buff syncBuffer
+
+	logger := newTestLogger(&buff)
+	accessLog := servers.WithHTTPAccessLog(logger, servers.HTTPAccessLogConfig{ExcludePaths: []string{"/health"}})
+
+	s := serverstest.Start(t,
+		serverstest.WithGRPC(servers.WithRegisterService(newGRPCTestServer(ctxd.NoOpLogger{}))),
+		serverstest.WithGateway(gRPCRestStreamTestServer{}, gRPCRestConnTestServer{}),
+		serverstest.WithGRPCRest(accessLog, servers.WithHTTPMiddleware(servers.HTTPRequestID())),
+		serverstest.WithHealthCheck(accessLog),
+		serverstest.WithMetrics(accessLog),
+		serverstest.WithPProf(accessLog),
+	)
+
+	serverstest.Get(t, s.GRPCRestURL+"/say/test")
+	serverstest.Get(t, s.GRPCRestURL+"/hellos/a,fail")
+	serverstest.Get(t, s.GRPCRestURL+"/unknown")
+	serverstest.Get(t, s.HealthCheckURL+"/health")
+	serverstest.Get(t, s.HealthCheckURL+"/")
+	serverstest.Get(t, s.MetricsURL+"/metrics")
+	serverstest.Get(t, s.PProfURL+"/debug/pprof/cmdline")
+
+	// The health check probes the GRPCRest root too.
+	byPath := make(map[string]map[string]any)
+
+	for _, l := range accessLogLines(t, &buff) {
+		byPath[l["http.path"].(string)] = l //nolint:forcetypeassert
+	}
+
+	assert.NotContains(t, byPath, "/health", "excluded path logged")
+
+	l := byPath["/say/test"]
+	require.NotNil(t, l)
+	assert.Equal(t, "info", l["level"])
+	assert.Equal(t, "GET", l["http.method"])
+	assert.Equal(t, "/say/{name=*}", l["http.route"])
+	assert.InDelta(t, http.StatusOK, l["http.status"], 0)
+	assert.Positive(t, l["http.bytes"])
+	assert.Contains(t, l, "http.latency_ms")
+	assert.NotEmpty(t, l["http.remote_addr"])
+	assert.NotEmpty(t, l["request_id"])
+
+	assert.Equal(t, "/hellos/{name=*}", byPath["/hellos/a,fail"]["http.route"])
+
+	l = byPath["/unknown"]
+	require.NotNil(t, l)
+	assert.Equal(t, "warn", l["level"])
+	assert.Equal(t, "", l["http.route"])
+	assert.InDelta(t, http.StatusNotFound, l["http.status"], 0)
+
+	assert.Equal(t, "/", byPath["/"]["http.route"])
+	assert.Equal(t, "/metrics", byPath["/metrics"]["http.route"])
+	assert.Equal(t, "/debug/pprof/cmdline", byPath["/debug/pprof/cmdline"]["http.route"])
+}
+
+func TestHTTPAccessLog_sampling(t *testing.T) {
+	var buff syncBuffer
+
+	h := servers.HTTPAccessLog(newTestLogger(&buff), servers.HTTPAccessLogConfig{SuccessSampleRate: 0.000001})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/fail" {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}),
+	)
+
+	for range 10 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	lines := accessLogLines(t, &buff)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "error", lines[0]["level"])
+	assert.Equal(t, "/fail", lines[0]["http.path"])
+}
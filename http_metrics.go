@@ -0,0 +1,184 @@
+package servers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// httpUnmatchedRoute is the route label of the requests no route matched.
+const httpUnmatchedRoute = "unmatched"
+
+// httpMethods are the methods used as label, the other methods are labeled "other".
+var httpMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// HTTPMetricsConfig contains configuration options for a HTTPMetrics.
+type HTTPMetricsConfig struct {
+	// DurationBuckets are the buckets in seconds of the request duration histogram, prometheus.DefBuckets by default.
+	DurationBuckets []float64
+	// SizeBuckets are the buckets in bytes of the request and response size histograms, from 100B to 100MB by
+	// default.
+	SizeBuckets []float64
+}
+
+// HTTPMetrics instruments the requests served by the HTTP servers, see WithHTTPMetrics. It is a
+// prometheus.Collector exporting the request count, the server errors, the duration and the request and response
+// sizes, by server, method and route. The route is the route pattern, either the chi pattern or the gateway path
+// template, rather than the path, so the number of series is bounded.
+type HTTPMetrics struct {
+	requests     *prometheus.CounterVec
+	errors       *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+}
+
+// NewHTTPMetrics creates a HTTPMetrics.
+func NewHTTPMetrics(config HTTPMetricsConfig) *HTTPMetrics {
+	if len(config.DurationBuckets) == 0 {
+		config.DurationBuckets = prometheus.DefBuckets
+	}
+
+	if len(config.SizeBuckets) == 0 {
+		config.SizeBuckets = prometheus.ExponentialBuckets(100, 10, 7)
+	}
+
+	labels := []string{"server", "method", "route"}
+	codeLabels := []string{"server", "method", "route", "code"}
+
+	return &HTTPMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests served.",
+		}, codeLabels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_request_errors_total",
+			Help: "Total number of HTTP requests served with a server error status.",
+		}, codeLabels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of the HTTP requests in seconds.",
+			Buckets: config.DurationBuckets,
+		}, labels),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_size_bytes",
+			Help:    "Size of the HTTP request bodies in bytes.",
+			Buckets: config.SizeBuckets,
+		}, labels),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "Size of the HTTP response bodies in bytes.",
+			Buckets: config.SizeBuckets,
+		}, labels),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *HTTPMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.errors.Describe(ch)
+	m.duration.Describe(ch)
+	m.requestSize.Describe(ch)
+	m.responseSize.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *HTTPMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.errors.Collect(ch)
+	m.duration.Collect(ch)
+	m.requestSize.Collect(ch)
+	m.responseSize.Collect(ch)
+}
+
+// WithHTTPMetrics instruments the requests served by the server with the metrics, labeled with the server name.
+// The metrics are registered by the Metrics server the instrumented server is linked to, see WithHTTPServer, or
+// by the Metrics server the option is applied to.
+// Apply to REST server instances, which includes GRPCRest, HealthCheck and the PProf server, and to Metrics server
+// instances.
+func WithHTTPMetrics(m *HTTPMetrics) Option {
+	return func(srv any) {
+		switch s := srv.(type) {
+		case *REST:
+			s.httpMetrics = m
+			s.middlewares = append(s.middlewares, func(next http.Handler) http.Handler {
+				return m.Handler(s.Name(), next)
+			})
+		case *Metrics:
+			if s.httpMetrics == m {
+				return
+			}
+
+			s.httpMetrics = m
+
+			WithCollector(m)(s)
+		}
+	}
+}
+
+// Handler instruments the requests served by next, labeled with the server name.
+func (m *HTTPMetrics) Handler(server string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := newHTTPResponseRecorder(w)
+		body := &countingReadCloser{ReadCloser: r.Body}
+		r, route := withHTTPRoute(r)
+
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+
+		next.ServeHTTP(rw, r)
+
+		method := r.Method
+		if !httpMethods[method] {
+			method = "other"
+		}
+
+		pattern := httpRoutePattern(r, route)
+		if pattern == "" {
+			pattern = httpUnmatchedRoute
+		}
+
+		code := strconv.Itoa(rw.Status())
+
+		m.requests.WithLabelValues(server, method, pattern, code).Inc()
+
+		if rw.Status() >= http.StatusInternalServerError {
+			m.errors.WithLabelValues(server, method, pattern, code).Inc()
+		}
+
+		m.duration.WithLabelValues(server, method, pattern).Observe(time.Since(start).Seconds())
+		// The body may not be read, e.g. when the request is rejected.
+		m.requestSize.WithLabelValues(server, method, pattern).Observe(float64(max(body.n.Load(), r.ContentLength)))
+		m.responseSize.WithLabelValues(server, method, pattern).Observe(float64(rw.bytes))
+	})
+}
+
+// countingReadCloser counts the bytes read, the streaming handlers may read after returning.
+type countingReadCloser struct {
+	io.ReadCloser
+
+	n atomic.Int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n.Add(int64(n))
+
+	return n, err
+}
@@ -0,0 +1,77 @@
+package servers_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bool64/ctxd"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/serverstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithHTTPMetrics(t *testing.T) {
+	m := servers.NewHTTPMetrics(servers.HTTPMetricsConfig{})
+
+	s := serverstest.Start(t,
+		serverstest.WithName("api"),
+		serverstest.WithGRPC(servers.WithRegisterService(newGRPCTestServer(ctxd.NoOpLogger{}))),
+		serverstest.WithGateway(gRPCRestConnTestServer{}),
+		serverstest.WithGRPCRest(servers.WithHTTPMetrics(m), servers.WithHTTPMiddleware(
+			servers.HTTPRecovery(ctxd.NoOpLogger{}),
+			func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/say/panic" {
+						panic("boom")
+					}
+
+					next.ServeHTTP(w, r)
+				})
+			},
+		)),
+		serverstest.WithPProf(servers.WithHTTPMetrics(m)),
+		// The metrics are registered by linking the instrumented servers.
+		serverstest.WithMetrics(),
+	)
+
+	serverstest.Get(t, s.GRPCRestURL+"/say/a")
+	serverstest.Get(t, s.GRPCRestURL+"/say/b")
+	serverstest.Get(t, s.GRPCRestURL+"/say/panic")
+	serverstest.Get(t, s.GRPCRestURL+"/unknown/a")
+	serverstest.Get(t, s.GRPCRestURL+"/unknown/b")
+	serverstest.Get(t, s.PProfURL+"/debug/pprof/cmdline")
+
+	req, err := http.NewRequest(http.MethodPost, s.GRPCRestURL+"/say/c", strings.NewReader(`{}`))
+	require.NoError(t, err)
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	res = serverstest.Get(t, s.MetricsURL+"/metrics")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	metrics := string(body)
+
+	for _, line := range []string{
+		`http_requests_total{code="200",method="GET",route="/say/{name=*}",server="api"} 2`,
+		`http_requests_total{code="404",method="GET",route="unmatched",server="api"} 2`,
+		`http_requests_total{code="501",method="POST",route="unmatched",server="api"} 1`,
+		`http_requests_total{code="200",method="GET",route="/debug/pprof/cmdline",server="api"} 1`,
+		// The panic happens before the request is routed.
+		`http_request_errors_total{code="500",method="GET",route="unmatched",server="api"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/say/{name=*}",server="api"} 2`,
+		`http_request_size_bytes_sum{method="POST",route="unmatched",server="api"} 2`,
+		`http_response_size_bytes_count{method="GET",route="unmatched",server="api"} 3`,
+	} {
+		assert.Contains(t, metrics, line)
+	}
+
+	assert.NotContains(t, metrics, `route="/unknown/a"`)
+}
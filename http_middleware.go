@@ -0,0 +1,239 @@
+package servers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/google/uuid"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader is the header carrying the request ID.
+const RequestIDHeader = "X-Request-Id"
+
+// WithHTTPMiddleware wraps the handler of the server with the middlewares, the first one being the outermost,
+// repeated options included. The middlewares wrap the handler as wrapped by the other options, e.g.
+// WithHTTPCompression.
+// Apply to REST server instances, which includes GRPCRest, HealthCheck and the PProf server, and to Metrics server
+// instances.
+func WithHTTPMiddleware(middlewares ...func(http.Handler) http.Handler) Option {
+	return func(srv any) {
+		switch s := srv.(type) {
+		case *REST:
+			s.middlewares = append(s.middlewares, middlewares...)
+		case *Metrics:
+			s.middlewares = append(s.middlewares, middlewares...)
+		}
+	}
+}
+
+// HTTPRecovery recovers from the panics of the handler, logging them with the stack and replying with an internal
+// error when nothing was written yet. http.ErrAbortHandler panics are not recovered, to abort the response.
+func HTTPRecovery(logger ctxd.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := newHTTPResponseRecorder(w)
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+
+				if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(rec)
+				}
+
+				logger.Error(r.Context(), "panic serving request",
+					"panic", fmt.Sprint(rec),
+					"stack", string(debug.Stack()),
+					"http.method", r.Method,
+					"http.path", r.URL.Path,
+				)
+
+				if !rw.wroteHeader {
+					writeErrorResponse(w, status.New(codes.Internal, "internal error"), http.StatusInternalServerError)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+type requestIDCtxKey struct{}
+
+// RequestIDFromContext returns the request ID set by HTTPRequestID.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string) //nolint:errcheck
+
+	return id
+}
+
+// HTTPRequestID identifies the requests by the X-Request-Id header, generating the ID when it is missing. The ID
+// is sent back in the response header, added to the context logging fields as request_id, and forwarded by the
+// gateway to the gRPC services as the x-request-id metadata.
+func HTTPRequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" {
+				id = uuid.New().String()
+
+				r.Header.Set(RequestIDHeader, id)
+			}
+
+			r.Header.Set(runtime.MetadataHeaderPrefix+RequestIDHeader, id)
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := context.WithValue(r.Context(), requestIDCtxKey{}, id)
+			ctx = ctxd.AddFields(ctx, "request_id", id)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// HTTPBodyLimit limits the size of the request bodies to limit bytes, replying 413 Request Entity Too Large to the
+// requests declaring a larger body. The handlers reading past the limit get an *http.MaxBytesError.
+func HTTPBodyLimit(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				writeErrorResponse(w, status.New(codes.InvalidArgument, "request body too large"), http.StatusRequestEntityTooLarge)
+
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HTTPSecureHeaders sets the security headers of the responses: X-Content-Type-Options, X-Frame-Options,
+// Referrer-Policy, and Strict-Transport-Security on TLS connections.
+func HTTPSecureHeaders() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Referrer-Policy", "no-referrer")
+
+			if r.TLS != nil {
+				h.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HTTPTimeout sets the deadline of the request contexts to timeout, so the gateway calls fail with
+// DeadlineExceeded once it passes. Streams are limited as well, upgraded connections are not.
+func HTTPTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// writeErrorResponse writes the status as the JSON error response of the gateway.
+func writeErrorResponse(w http.ResponseWriter, st *status.Status, httpStatus int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+
+	_ = json.NewEncoder(w).Encode(newErrorResponse(st, httpStatus)) //nolint:errcheck
+}
+
+// httpResponseRecorder records the status and the size of a response, keeping the optional interfaces of the
+// wrapped writer used by the streams and the upgraded connections.
+type httpResponseRecorder struct {
+	http.ResponseWriter
+
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func newHTTPResponseRecorder(w http.ResponseWriter) *httpResponseRecorder {
+	return &httpResponseRecorder{ResponseWriter: w}
+}
+
+// Status returns the status of the response, 200 when the handler did not write it.
+func (rw *httpResponseRecorder) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+
+	return rw.status
+}
+
+func (rw *httpResponseRecorder) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.status = code
+
+		// Informational responses are followed by the actual one.
+		rw.wroteHeader = code >= http.StatusOK || code == http.StatusSwitchingProtocols
+	}
+
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *httpResponseRecorder) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+
+	return n, err
+}
+
+func (rw *httpResponseRecorder) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	_ = http.NewResponseController(rw.ResponseWriter).Flush() //nolint:errcheck
+}
+
+// Hijack implements http.Hijacker, for the WebSocket upgrades which need it directly.
+func (rw *httpResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil && !rw.wroteHeader {
+		rw.status = http.StatusSwitchingProtocols
+		rw.wroteHeader = true
+	}
+
+	return conn, brw, err
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (rw *httpResponseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
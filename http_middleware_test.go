@@ -0,0 +1,185 @@
+package servers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/bool64/zapctxd"
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/serverstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestLogger(buff *syncBuffer) ctxd.Logger {
+	return zapctxd.New(zapctxd.Config{
+		FieldNames: ctxd.FieldNames{
+			Timestamp: "timestamp",
+			Message:   "message",
+		},
+		Level:  zap.DebugLevel,
+		Output: buff,
+	})
+}
+
+func TestWithHTTPMiddleware(t *testing.T) {
+	var order []string
+
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	s := serverstest.Start(t,
+		serverstest.WithPProf(
+			servers.WithHTTPMiddleware(mw("first"), mw("second")),
+			servers.WithHTTPMiddleware(mw("third")),
+			servers.WithHTTPMiddleware(servers.HTTPSecureHeaders()),
+		),
+		serverstest.WithHealthCheck(servers.WithHTTPMiddleware(servers.HTTPRequestID())),
+	)
+
+	res := serverstest.Get(t, s.PProfURL+"/version")
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, []string{"first", "second", "third"}, order)
+	assert.Equal(t, "nosniff", res.Header.Get("X-Content-Type-Options"))
+
+	res = serverstest.Get(t, s.HealthCheckURL+"/health")
+
+	assert.NotEmpty(t, res.Header.Get("X-Request-Id"))
+}
+
+func TestWithHTTPMiddleware_gateway(t *testing.T) {
+	s := serverstest.Start(t,
+		serverstest.WithGRPC(servers.WithRegisterService(newGRPCTestServer(ctxd.NoOpLogger{}))),
+		serverstest.WithGateway(gRPCRestStreamTestServer{}),
+		serverstest.WithGRPCRest(
+			servers.WithWebSocket(servers.WebSocketConfig{}),
+			servers.WithHTTPMiddleware(
+				servers.HTTPRecovery(ctxd.NoOpLogger{}),
+				servers.HTTPRequestID(),
+				servers.HTTPAccessLog(ctxd.NoOpLogger{}, servers.HTTPAccessLogConfig{}),
+				servers.HTTPTimeout(20*time.Millisecond),
+			),
+		),
+	)
+
+	t.Run("stream", func(t *testing.T) {
+		res := serverstest.Get(t, s.GRPCRestURL+"/hellos/a,b")
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotEmpty(t, res.Header.Get("X-Request-Id"))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		res := serverstest.Get(t, s.GRPCRestURL+"/hellos/a,wait,b")
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(body), "Hello a")
+		assert.NotContains(t, string(body), "Hello b")
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		received, closeErr := chatWebSocket(t, s.GRPCRestURL+"/chat", nil, `{"name":"a"}`)
+
+		assert.Equal(t, []string{`{"message":"Hello a"}`}, received)
+		assert.Equal(t, 1000, closeErr.Code)
+	})
+}
+
+func TestHTTPRecovery(t *testing.T) {
+	var buff syncBuffer
+
+	h := servers.HTTPRecovery(newTestLogger(&buff))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"code":500,"message":"internal error"}`, rec.Body.String())
+
+	l := findLogLine(logLines(t, &buff), "panic serving request")
+	require.NotNil(t, l)
+	assert.Equal(t, "boom", l["panic"])
+	assert.Equal(t, "/panic", l["http.path"])
+
+	h = servers.HTTPRecovery(ctxd.NoOpLogger{})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestHTTPRequestID(t *testing.T) {
+	var ids []string
+
+	h := servers.HTTPRequestID()(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		ids = append(ids, servers.RequestIDFromContext(r.Context()), r.Header.Get("Grpc-Metadata-X-Request-Id"))
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-Id", "request-1")
+
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "request-1", rec.Header().Get("X-Request-Id"))
+	assert.Equal(t, []string{"request-1", "request-1"}, ids)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Len(t, ids, 4)
+	assert.NotEmpty(t, ids[2])
+	assert.Equal(t, ids[2], rec.Header().Get("X-Request-Id"))
+}
+
+func TestHTTPBodyLimit(t *testing.T) {
+	h := servers.HTTPBodyLimit(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 8)
+
+		if _, err := r.Body.Read(buf); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("body too large")))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Contains(t, rec.Body.String(), "request body too large")
+
+	// Undeclared length.
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("body too large"))
+	req.ContentLength = -1
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("ok")))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
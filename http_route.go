@@ -0,0 +1,75 @@
+package servers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+type httpRouteCtxKey struct{}
+
+// httpRoute is the route pattern of a request, known once the router matched it. It is shared through the request
+// context, so the middlewares wrapping the router can read it after serving the request.
+type httpRoute struct {
+	pattern string
+}
+
+// withHTTPRoute returns the request with a route to be set by the router, or the route already set up.
+// A chi route context is set up as well, so the chi routers record their pattern in it.
+func withHTTPRoute(r *http.Request) (*http.Request, *httpRoute) {
+	if route, ok := r.Context().Value(httpRouteCtxKey{}).(*httpRoute); ok {
+		return r, route
+	}
+
+	route := &httpRoute{}
+	ctx := context.WithValue(r.Context(), httpRouteCtxKey{}, route)
+
+	if chi.RouteContext(ctx) == nil {
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, chi.NewRouteContext())
+	}
+
+	return r.WithContext(ctx), route
+}
+
+// httpRoutePattern returns the route pattern of the request served with the request returned by withHTTPRoute,
+// empty when no route matched it.
+func httpRoutePattern(r *http.Request, route *httpRoute) string {
+	if route.pattern != "" {
+		return route.pattern
+	}
+
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+
+	return ""
+}
+
+// setHTTPRoute sets the route pattern of the request, when withHTTPRoute set up the route.
+func setHTTPRoute(ctx context.Context, pattern string) {
+	if route, ok := ctx.Value(httpRouteCtxKey{}).(*httpRoute); ok {
+		route.pattern = pattern
+	}
+}
+
+// gatewayRouteMiddleware sets the route pattern of the requests to the path template of the gateway handler.
+func gatewayRouteMiddleware(next runtime.HandlerFunc) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		if p, ok := runtime.HTTPPattern(r.Context()); ok {
+			setHTTPRoute(r.Context(), p.String())
+		}
+
+		next(w, r, pathParams)
+	}
+}
+
+// routedHandler sets the route pattern of the requests to pattern, for the handlers routed by http.ServeMux.
+func routedHandler(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setHTTPRoute(r.Context(), pattern)
+
+		next.ServeHTTP(w, r)
+	})
+}
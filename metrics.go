@@ -59,6 +59,22 @@ func WithGRPCServer(grpcSrv *GRPC) Option {
 	}
 }
 
+// WithHTTPServer links the REST server, registering the HTTPMetrics it is instrumented with, see WithHTTPMetrics.
+// The REST server of GRPCRest, HealthCheck and the PProf server is linked the same way.
+func WithHTTPServer(restSrv *REST) Option {
+	return func(srv any) {
+		s, ok := srv.(*Metrics)
+		if !ok {
+			// skip does not apply to this instance.
+			return
+		}
+
+		if restSrv.httpMetrics != nil {
+			WithHTTPMetrics(restSrv.httpMetrics)(s)
+		}
+	}
+}
+
 // WithExposeAt sets the path to expose the metrics.
 func WithExposeAt(path string) Option {
 	return func(srv any) {
@@ -101,6 +117,10 @@ type Metrics struct {
 
 	registered map[string]bool
 	collectors []prometheus.Collector
+
+	compression *Compression
+	httpMetrics *HTTPMetrics
+	middlewares []func(http.Handler) http.Handler
 }
 
 // Start starts serving the Metrics server.
@@ -117,12 +137,20 @@ func (srv *Metrics) Start() error {
 	mux := http.NewServeMux()
 
 	// Register the /metrics endpoint handler.
-	mux.Handle(srv.exposeAt, promhttp.HandlerFor(
+	var handler http.Handler = promhttp.HandlerFor(
 		reg,
 		promhttp.HandlerOpts{
 			EnableOpenMetrics: true, // Enable OpenMetrics format.
+			// Responses are compressed by WithHTTPCompression when set.
+			DisableCompression: srv.compression != nil,
 		},
-	))
+	)
+
+	if srv.compression != nil {
+		handler = srv.compression.Handler(handler)
+	}
+
+	mux.Handle(srv.exposeAt, routedHandler(srv.exposeAt, handler))
 
 	if srv.grpcServer != nil {
 		// Register gRPC metrics with the custom registry.
@@ -140,6 +168,10 @@ func (srv *Metrics) Start() error {
 	// Use the custom mux with the /metrics handler.
 	srv.httpServer.Handler = mux
 
+	for i := len(srv.middlewares) - 1; i >= 0; i-- {
+		srv.httpServer.Handler = srv.middlewares[i](srv.httpServer.Handler)
+	}
+
 	if err := srv.serve(srv.httpServer, srv.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("%w: %w addr %s", err, ErrMetricsStart, srv.listener.Addr().String())
 	}
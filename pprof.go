@@ -9,7 +9,7 @@ import (
 
 // NewPProf creates a new REST service dedicated to serving pprof routes.
 // This service is designed as an alternative to the default pprof handler, eliminating the necessity to rely on `http.DefaultServeMux`.
-func NewPProf(cfg Config) *REST {
+func NewPProf(cfg Config, opts ...Option) *REST {
 	r := chi.NewRouter()
 
 	r.Method(http.MethodGet, "/", NewRestRootHandler(cfg.Name))
@@ -30,6 +30,6 @@ func NewPProf(cfg Config) *REST {
 			Port: cfg.Port,
 		},
 		r,
-		WithAddrAssigned(),
+		append([]Option{WithAddrAssigned()}, opts...)...,
 	)
 }
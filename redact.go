@@ -0,0 +1,285 @@
+package servers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// redactedValue is the value set in string and bytes fields that are redacted.
+const redactedValue = "[REDACTED]"
+
+// redactMessage returns a copy of msg with the fields marked with the debug_redact option and the fields
+// in paths masked.
+//
+// A path is the dot separated list of proto field names from the root message, e.g. "user.password".
+// Paths descend into repeated and map message fields. String and bytes fields are replaced by
+// "[REDACTED]", any other field is cleared.
+func redactMessage(msg proto.Message, paths []string) proto.Message {
+	if msg == nil {
+		return nil
+	}
+
+	m := proto.Clone(msg)
+
+	redactDebugFields(m.ProtoReflect())
+
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+
+		redactPath(m.ProtoReflect(), strings.Split(p, "."))
+	}
+
+	return m
+}
+
+func redactPath(m protoreflect.Message, path []string) {
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+	if fd == nil || !m.Has(fd) {
+		return
+	}
+
+	if len(path) == 1 {
+		redactField(m, fd)
+
+		return
+	}
+
+	if fd.Message() == nil {
+		return
+	}
+
+	switch {
+	case fd.IsList():
+		l := m.Mutable(fd).List()
+
+		for i := 0; i < l.Len(); i++ {
+			redactPath(l.Get(i).Message(), path[1:])
+		}
+	case fd.IsMap():
+		if fd.MapValue().Message() == nil {
+			return
+		}
+
+		m.Mutable(fd).Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+			redactPath(v.Message(), path[1:])
+
+			return true
+		})
+	default:
+		redactPath(m.Mutable(fd).Message(), path[1:])
+	}
+}
+
+// redactDebugFields masks, recursively, the fields marked with the debug_redact option.
+func redactDebugFields(m protoreflect.Message) {
+	var redact []protoreflect.FieldDescriptor
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if opts, ok := fd.Options().(*descriptorpb.FieldOptions); ok && opts.GetDebugRedact() {
+			// Fields are not mutated while ranging over the message.
+			redact = append(redact, fd)
+
+			return true
+		}
+
+		if fd.Message() == nil {
+			return true
+		}
+
+		switch {
+		case fd.IsList():
+			l := v.List()
+
+			for i := 0; i < l.Len(); i++ {
+				redactDebugFields(l.Get(i).Message())
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				return true
+			}
+
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				redactDebugFields(mv.Message())
+
+				return true
+			})
+		default:
+			redactDebugFields(v.Message())
+		}
+
+		return true
+	})
+
+	for _, fd := range redact {
+		redactField(m, fd)
+	}
+}
+
+func redactField(m protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	if fd.IsList() || fd.IsMap() {
+		m.Clear(fd)
+
+		return
+	}
+
+	switch fd.Kind() { //nolint:exhaustive
+	case protoreflect.StringKind:
+		m.Set(fd, protoreflect.ValueOfString(redactedValue))
+	case protoreflect.BytesKind:
+		m.Set(fd, protoreflect.ValueOfBytes([]byte(redactedValue)))
+	default:
+		m.Clear(fd)
+	}
+}
+
+// debugRedactJSONFields returns the JSON and proto names of the fields marked with the debug_redact option in the
+// registered proto files. The JSON documents do not tell their message, so the fields are masked by name.
+var debugRedactJSONFields = sync.OnceValue(func() map[string]bool {
+	fields := make(map[string]bool)
+
+	protoregistry.GlobalFiles.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		collectDebugRedactFields(fd.Messages(), fields)
+
+		return true
+	})
+
+	return fields
+})
+
+func collectDebugRedactFields(msgs protoreflect.MessageDescriptors, fields map[string]bool) {
+	for i := 0; i < msgs.Len(); i++ {
+		md := msgs.Get(i)
+
+		for j := 0; j < md.Fields().Len(); j++ {
+			fd := md.Fields().Get(j)
+
+			if opts, ok := fd.Options().(*descriptorpb.FieldOptions); ok && opts.GetDebugRedact() {
+				fields[fd.JSONName()] = true
+				fields[string(fd.Name())] = true
+			}
+		}
+
+		collectDebugRedactFields(md.Messages(), fields)
+	}
+}
+
+// redactJSON masks the values of the fields in paths and of the fields named as a debug_redact field, see
+// debugRedactJSONFields, of the JSON documents. Paths descend into arrays.
+// The data may be a sequence of documents, as streamed by the gateway. It reports false when the data is not JSON,
+// so it could not be redacted.
+func redactJSON(data []byte, paths []string) ([]byte, bool) {
+	fields := debugRedactJSONFields()
+
+	if len(paths) == 0 && len(fields) == 0 {
+		return data, true
+	}
+
+	var (
+		redacted bytes.Buffer
+		enc      = json.NewEncoder(&redacted)
+		dec      = json.NewDecoder(bytes.NewReader(data))
+	)
+
+	enc.SetEscapeHTML(false)
+
+	for {
+		var doc any
+
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, false
+		}
+
+		redactJSONFields(doc, fields)
+
+		for _, p := range paths {
+			if p == "" {
+				continue
+			}
+
+			redactJSONPath(doc, strings.Split(p, "."))
+		}
+
+		if err := enc.Encode(doc); err != nil {
+			return nil, false
+		}
+	}
+
+	// The encoder terminates every document with a new line, the single document keeps the original form.
+	if bytes.Count(redacted.Bytes(), []byte("\n")) == 1 && !bytes.HasSuffix(data, []byte("\n")) {
+		return bytes.TrimSuffix(redacted.Bytes(), []byte("\n")), true
+	}
+
+	return redacted.Bytes(), true
+}
+
+func redactJSONFields(doc any, fields map[string]bool) {
+	switch v := doc.(type) {
+	case []any:
+		for _, e := range v {
+			redactJSONFields(e, fields)
+		}
+	case map[string]any:
+		for k, f := range v {
+			if fields[k] {
+				v[k] = redactedValue
+
+				continue
+			}
+
+			redactJSONFields(f, fields)
+		}
+	}
+}
+
+func redactJSONPath(doc any, path []string) {
+	switch v := doc.(type) {
+	case []any:
+		for _, e := range v {
+			redactJSONPath(e, path)
+		}
+	case map[string]any:
+		f, ok := v[path[0]]
+		if !ok {
+			return
+		}
+
+		if len(path) == 1 {
+			v[path[0]] = redactedValue
+
+			return
+		}
+
+		redactJSONPath(f, path[1:])
+	}
+}
+
+// marshalPayload renders the payload as JSON, falling back to nil if it is not a proto message.
+func marshalPayload(payload any) []byte {
+	msg, ok := payload.(proto.Message)
+	if !ok || msg == nil {
+		return nil
+	}
+
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil
+	}
+
+	return data
+}
@@ -15,7 +15,9 @@ var ErrRESTStart = errors.New("start REST server")
 type REST struct {
 	*Server
 
-	httpServer *http.Server
+	httpServer  *http.Server
+	middlewares []func(http.Handler) http.Handler
+	httpMetrics *HTTPMetrics
 }
 
 // NewREST constructs a new rest Server.
@@ -29,6 +31,14 @@ func NewREST(config Config, handler http.Handler, opts ...Option) *REST {
 		ReadTimeout: 400 * time.Millisecond,
 	}
 
+	for _, o := range opts {
+		o(&srv)
+	}
+
+	for i := len(srv.middlewares) - 1; i >= 0; i-- {
+		srv.httpServer.Handler = srv.middlewares[i](srv.httpServer.Handler)
+	}
+
 	return &srv
 }
 
@@ -0,0 +1,108 @@
+package serverstest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorResponse is the JSON body of the GRPCRest error responses.
+type ErrorResponse struct {
+	Code    int                   `json:"code"`
+	Message string                `json:"message,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	Details []ErrorResponseDetail `json:"details,omitempty"`
+}
+
+// ErrorResponseDetail is a detail of an ErrorResponse.
+type ErrorResponseDetail struct {
+	Field       string `json:"field,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// AssertErrorResponse asserts the response is an error response with the HTTP status, message and details,
+// given as field to description, and an error id. It reads the response body.
+func AssertErrorResponse(t testing.TB, res *http.Response, httpStatus int, message string, details map[string]string) ErrorResponse {
+	t.Helper()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	var e ErrorResponse
+
+	require.NoError(t, json.Unmarshal(body, &e), "error response: %s", body)
+
+	assert.Equal(t, httpStatus, res.StatusCode, "status code")
+	assert.Equal(t, httpStatus, e.Code, "error response code")
+	assert.Equal(t, message, e.Message, "error response message")
+	assert.NotEmpty(t, e.Error, "error response error id")
+
+	got := make(map[string]string, len(e.Details))
+
+	for _, d := range e.Details {
+		got[d.Field] = d.Description
+	}
+
+	if details == nil {
+		details = map[string]string{}
+	}
+
+	assert.Equal(t, details, got, "error response details")
+
+	return e
+}
+
+// AssertStatus asserts the error is a gRPC status with the code, message and ErrorInfo metadata, error id aside.
+// It returns the ErrorInfo, nil when the status has none.
+func AssertStatus(t testing.TB, err error, code codes.Code, message string, details map[string]string) *errdetails.ErrorInfo {
+	t.Helper()
+
+	require.Error(t, err)
+
+	st, ok := status.FromError(err)
+	require.True(t, ok, "gRPC status error: %v", err)
+
+	assert.Equal(t, code, st.Code(), "status code")
+	assert.Equal(t, message, st.Message(), "status message")
+
+	var info *errdetails.ErrorInfo
+
+	for _, d := range st.Details() {
+		if i, ok := d.(*errdetails.ErrorInfo); ok {
+			info = i
+
+			break
+		}
+	}
+
+	if !assert.NotNil(t, info, "status ErrorInfo detail") {
+		return nil
+	}
+
+	got := make(map[string]string, len(info.GetMetadata()))
+
+	for k, v := range info.GetMetadata() {
+		if k == "error_id" {
+			assert.NotEmpty(t, v, "status error id")
+
+			continue
+		}
+
+		got[k] = v
+	}
+
+	if details == nil {
+		details = map[string]string{}
+	}
+
+	assert.Equal(t, details, got, "status details")
+
+	return info
+}
@@ -0,0 +1,264 @@
+// Package serverstest starts servers on ephemeral ports for end-to-end tests.
+package serverstest
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/dohernandez/servers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Option configures the servers started by Start.
+type Option func(c *config)
+
+type config struct {
+	name string
+
+	grpc        []servers.Option
+	grpcRest    []servers.Option
+	healthCheck []servers.Option
+	metrics     []servers.Option
+	pprof       []servers.Option
+	gateway     []servers.GRPCRestRegisterServiceConn
+
+	withGRPC, withGRPCRest, withHealthCheck, withMetrics, withPProf bool
+}
+
+// WithName sets the name of the servers, "Test service" by default.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithGRPC starts a GRPC server with the options, e.g. servers.WithRegisterService.
+func WithGRPC(opts ...servers.Option) Option {
+	return func(c *config) {
+		c.withGRPC = true
+		c.grpc = append(c.grpc, opts...)
+	}
+}
+
+// WithGRPCRest starts a GRPCRest server with the options.
+func WithGRPCRest(opts ...servers.Option) Option {
+	return func(c *config) {
+		c.withGRPCRest = true
+		c.grpcRest = append(c.grpcRest, opts...)
+	}
+}
+
+// WithGateway starts a GRPCRest server registering the service handlers on the in-process connection of the GRPC
+// server, see servers.WithInProcessGRPC. It requires WithGRPC.
+func WithGateway(services ...servers.GRPCRestRegisterServiceConn) Option {
+	return func(c *config) {
+		c.withGRPCRest = true
+		c.gateway = append(c.gateway, services...)
+	}
+}
+
+// WithHealthCheck starts a HealthCheck server with the options, checking the GRPC and GRPCRest servers when
+// started. The GRPC server then serves the gRPC health service.
+func WithHealthCheck(opts ...servers.Option) Option {
+	return func(c *config) {
+		c.withHealthCheck = true
+		c.healthCheck = append(c.healthCheck, opts...)
+	}
+}
+
+// WithMetrics starts a Metrics server with the options, collecting the GRPC server metrics and the HTTP metrics
+// of the servers instrumented with WithHTTPMetrics.
+func WithMetrics(opts ...servers.Option) Option {
+	return func(c *config) {
+		c.withMetrics = true
+		c.metrics = append(c.metrics, opts...)
+	}
+}
+
+// WithPProf starts a PProf server with the options.
+func WithPProf(opts ...servers.Option) Option {
+	return func(c *config) {
+		c.withPProf = true
+		c.pprof = append(c.pprof, opts...)
+	}
+}
+
+// Servers are the servers started by Start, nil when not requested.
+type Servers struct {
+	GRPC *servers.GRPC
+	// GRPCConn is a connection to the GRPC server address.
+	GRPCConn *grpc.ClientConn
+
+	GRPCRest *servers.GRPCRest
+	// GRPCRestURL is the base URL of the GRPCRest server, e.g. "http://127.0.0.1:41234".
+	GRPCRestURL string
+
+	HealthCheck    *servers.HealthCheck
+	HealthCheckURL string
+
+	Metrics    *servers.Metrics
+	MetricsURL string
+
+	PProf    *servers.REST
+	PProfURL string
+}
+
+// server is a server that can be started.
+type server interface {
+	Start() error
+	Stop()
+}
+
+// Start starts the requested servers on ephemeral ports, in dependency order: GRPC, GRPCRest, HealthCheck,
+// PProf and Metrics. The servers are stopped, in reverse order, when the test finishes.
+func Start(t testing.TB, opts ...Option) *Servers {
+	t.Helper()
+
+	c := config{name: "Test service"}
+
+	for _, o := range opts {
+		o(&c)
+	}
+
+	cfg := servers.Config{
+		Name: c.name,
+		Host: "127.0.0.1",
+	}
+
+	s := &Servers{}
+
+	if c.withGRPC {
+		grpcOpts := slices.Concat(c.grpc, []servers.Option{servers.WithAddrAssigned()})
+
+		if c.withHealthCheck {
+			// The health check server checks the gRPC health service.
+			grpcOpts = append(grpcOpts, servers.WithGrpcHealthCheck())
+		}
+
+		s.GRPC = servers.NewGRPC(cfg, grpcOpts...)
+		addr := start(t, s.GRPC, s.GRPC.AddrAssigned)
+
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("grpc connection: %v", err)
+		}
+
+		t.Cleanup(func() {
+			_ = conn.Close() //nolint:errcheck
+		})
+
+		s.GRPCConn = conn
+	}
+
+	if c.withGRPCRest {
+		grpcRestOpts := slices.Concat(c.grpcRest, []servers.Option{servers.WithAddrAssigned()})
+
+		if len(c.gateway) > 0 {
+			if s.GRPC == nil {
+				t.Fatal("serverstest: WithGateway requires WithGRPC")
+			}
+
+			grpcRestOpts = append(grpcRestOpts, servers.WithInProcessGRPC(s.GRPC, c.gateway...))
+		}
+
+		srv, err := servers.NewGRPCRest(cfg, grpcRestOpts...)
+		if err != nil {
+			t.Fatalf("new GRPCRest: %v", err)
+		}
+
+		s.GRPCRest = srv
+		s.GRPCRestURL = "http://" + start(t, srv, srv.AddrAssigned)
+	}
+
+	if c.withHealthCheck {
+		healthCheckOpts := slices.Concat(c.healthCheck, []servers.Option{servers.WithAddrAssigned()})
+
+		if s.GRPC != nil {
+			healthCheckOpts = append(healthCheckOpts, servers.WithGRPC(s.GRPC))
+		}
+
+		if s.GRPCRest != nil {
+			healthCheckOpts = append(healthCheckOpts, servers.WithGRPCRest(s.GRPCRest))
+		}
+
+		s.HealthCheck = servers.NewHealthCheck(cfg, healthCheckOpts...)
+		s.HealthCheckURL = "http://" + start(t, s.HealthCheck, s.HealthCheck.AddrAssigned)
+	}
+
+	if c.withPProf {
+		s.PProf = servers.NewPProf(cfg, c.pprof...)
+		s.PProfURL = "http://" + start(t, s.PProf, s.PProf.AddrAssigned)
+	}
+
+	if c.withMetrics {
+		metricsOpts := slices.Concat(c.metrics, []servers.Option{servers.WithAddrAssigned()})
+
+		if s.GRPC != nil {
+			metricsOpts = append(metricsOpts, servers.WithGRPCServer(s.GRPC))
+		}
+
+		// The HTTP metrics the servers are instrumented with are registered.
+		if s.GRPCRest != nil {
+			metricsOpts = append(metricsOpts, servers.WithHTTPServer(s.GRPCRest.REST))
+		}
+
+		if s.HealthCheck != nil {
+			metricsOpts = append(metricsOpts, servers.WithHTTPServer(s.HealthCheck.REST))
+		}
+
+		if s.PProf != nil {
+			metricsOpts = append(metricsOpts, servers.WithHTTPServer(s.PProf))
+		}
+
+		s.Metrics = servers.NewMetrics(cfg, metricsOpts...)
+		s.MetricsURL = "http://" + start(t, s.Metrics, s.Metrics.AddrAssigned)
+	}
+
+	return s
+}
+
+// start starts the server, waiting for its address, and stops it on cleanup.
+func start(t testing.TB, srv server, addrAssigned chan string) string {
+	t.Helper()
+
+	result := make(chan error, 1)
+
+	go func() {
+		result <- srv.Start()
+	}()
+
+	select {
+	case err := <-result:
+		t.Fatalf("start %T: %v", srv, err)
+
+		return ""
+	case addr := <-addrAssigned:
+		t.Cleanup(srv.Stop)
+
+		return addr
+	}
+}
+
+// Get sends a GET request to the URL, failing the test on error. The response body is closed on cleanup.
+func Get(t testing.TB, url string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+
+	t.Cleanup(func() {
+		_ = res.Body.Close() //nolint:errcheck
+	})
+
+	return res
+}
@@ -0,0 +1,75 @@
+package serverstest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/dohernandez/servers"
+	"github.com/dohernandez/servers/serverstest"
+	"github.com/dohernandez/servers/testdata"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+type greeter struct {
+	testdata.UnimplementedGreeterServer
+}
+
+func (greeter) SayHello(_ context.Context, req *testdata.HelloRequest) (*testdata.HelloReply, error) {
+	if req.GetName() == "denied" {
+		return nil, servers.Error(codes.PermissionDenied, "not allowed", map[string]string{"name": req.GetName()})
+	}
+
+	return &testdata.HelloReply{Message: "Hello " + req.GetName()}, nil
+}
+
+func (g greeter) RegisterService(sr grpc.ServiceRegistrar) {
+	testdata.RegisterGreeterServer(sr, g)
+}
+
+func (greeter) RegisterServiceHandlerConn(mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	return testdata.RegisterGreeterHandler(context.Background(), mux, conn)
+}
+
+func TestStart(t *testing.T) {
+	s := serverstest.Start(t,
+		serverstest.WithGRPC(servers.WithRegisterService(greeter{})),
+		serverstest.WithGateway(greeter{}),
+		serverstest.WithHealthCheck(),
+		serverstest.WithMetrics(),
+		serverstest.WithPProf(),
+	)
+
+	client := testdata.NewGreeterClient(s.GRPCConn)
+
+	reply, err := client.SayHello(context.Background(), &testdata.HelloRequest{Name: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello test", reply.GetMessage())
+
+	_, err = client.SayHello(context.Background(), &testdata.HelloRequest{Name: "denied"})
+	serverstest.AssertStatus(t, err, codes.PermissionDenied, "not allowed", map[string]string{"name": "denied"})
+
+	res := serverstest.Get(t, s.GRPCRestURL+"/say/test")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = serverstest.Get(t, s.GRPCRestURL+"/say/denied")
+	serverstest.AssertErrorResponse(t, res, http.StatusForbidden, "not allowed", map[string]string{"name": "denied"})
+
+	for _, url := range []string{s.HealthCheckURL + "/health", s.MetricsURL + "/metrics", s.PProfURL + "/debug/pprof/"} {
+		res = serverstest.Get(t, url)
+		assert.Equal(t, http.StatusOK, res.StatusCode, url)
+	}
+}
+
+func TestStart_only(t *testing.T) {
+	s := serverstest.Start(t, serverstest.WithPProf())
+
+	assert.Nil(t, s.GRPC)
+	assert.Nil(t, s.GRPCRest)
+	assert.Empty(t, s.GRPCRestURL)
+	assert.NotEmpty(t, s.PProfURL)
+}